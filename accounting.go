@@ -0,0 +1,157 @@
+// Copyright 2012 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package process
+
+import (
+	"encoding/csv"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Usage is what one tenant's programs used in one window of time. Runs
+// are counted in the window in which they ended.
+type Usage struct {
+	Tenant      string
+	Start       time.Time // start of the window
+	Runs        int
+	CPUSeconds  float64
+	GBSeconds   float64 // peak resident memory in GB times seconds run
+	OutputBytes int64   // stdout and stderr
+}
+
+// Accounting totals the resources used by the programs of each tenant,
+// such as a customer or a team, over fixed windows of time, for
+// chargeback. It is safe for concurrent use.
+type Accounting struct {
+	window time.Duration
+	now    func() time.Time // the time a run is recorded
+
+	mu    sync.Mutex
+	usage map[usageKey]*Usage
+}
+
+type usageKey struct {
+	tenant string
+	start  int64 // window start in Unix nanoseconds
+}
+
+// NewAccounting returns an Accounting that totals usage over windows of
+// the given length, such as time.Hour, aligned to the Unix epoch.
+func NewAccounting(window time.Duration) *Accounting {
+	return &Accounting{window: window, now: time.Now, usage: make(map[usageKey]*Usage)}
+}
+
+// Record adds the run that m, an "end" Message, reports to tenant's
+// usage. Other Messages, and runs that did not start, are ignored.
+func (a *Accounting) Record(tenant string, m *Message) {
+	if m.Kind != "end" || m.Stats == nil {
+		return
+	}
+	st := m.Stats
+	start := a.now().Truncate(a.window)
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	k := usageKey{tenant, start.UnixNano()}
+	u := a.usage[k]
+	if u == nil {
+		u = &Usage{Tenant: tenant, Start: start}
+		a.usage[k] = u
+	}
+	u.Runs++
+	u.CPUSeconds += st.CPU.Seconds()
+	u.GBSeconds += float64(st.MaxRSS) / 1e9 * st.Elapsed.Seconds()
+	u.OutputBytes += st.StdoutBytes + st.StderrBytes
+}
+
+// Tee returns a channel that wraps dest. Messages sent to the channel are
+// passed on to dest, and the "end" Message is recorded for tenant first.
+func (a *Accounting) Tee(tenant string, dest chan<- *Message) chan<- *Message {
+	ch := make(chan *Message)
+	go func() {
+		for m := range ch {
+			a.Record(tenant, m)
+			dest <- m
+			if m.Kind == "end" {
+				return
+			}
+		}
+	}()
+	return ch
+}
+
+// Usage returns the usage recorded in windows starting at or after from
+// and before to, ordered by window and then tenant. A zero from or to
+// leaves that end of the range open.
+func (a *Accounting) Usage(from, to time.Time) []Usage {
+	a.mu.Lock()
+	var us []Usage
+	for _, u := range a.usage {
+		if (from.IsZero() || !u.Start.Before(from)) && (to.IsZero() || u.Start.Before(to)) {
+			us = append(us, *u)
+		}
+	}
+	a.mu.Unlock()
+	sort.Slice(us, func(i, j int) bool {
+		if !us[i].Start.Equal(us[j].Start) {
+			return us[i].Start.Before(us[j].Start)
+		}
+		return us[i].Tenant < us[j].Tenant
+	})
+	return us
+}
+
+// Forget discards the usage recorded in windows starting before t, such
+// as once it has been exported.
+func (a *Accounting) Forget(t time.Time) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for k, u := range a.usage {
+		if u.Start.Before(t) {
+			delete(a.usage, k)
+		}
+	}
+}
+
+// WriteCSV writes us to w as CSV, with a header line, and times in RFC
+// 3339 format.
+func WriteCSV(w io.Writer, us []Usage) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"tenant", "window_start", "runs", "cpu_seconds", "gb_seconds", "output_bytes"})
+	for _, u := range us {
+		cw.Write([]string{
+			u.Tenant,
+			u.Start.UTC().Format(time.RFC3339),
+			strconv.Itoa(u.Runs),
+			strconv.FormatFloat(u.CPUSeconds, 'f', -1, 64),
+			strconv.FormatFloat(u.GBSeconds, 'f', -1, 64),
+			strconv.FormatInt(u.OutputBytes, 10),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// ServeHTTP serves the recorded usage as CSV. The query parameters from
+// and to, in RFC 3339 format, limit the windows served as for Usage.
+func (a *Accounting) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var from, to time.Time
+	for name, t := range map[string]*time.Time{"from": &from, "to": &to} {
+		v := r.FormValue(name)
+		if v == "" {
+			continue
+		}
+		var err error
+		if *t, err = time.Parse(time.RFC3339, v); err != nil {
+			http.Error(w, "bad "+name+": "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	WriteCSV(w, a.Usage(from, to))
+}
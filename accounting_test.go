@@ -0,0 +1,69 @@
+package process
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAccounting(t *testing.T) {
+	a := NewAccounting(time.Hour)
+	now := time.Date(2012, 3, 1, 9, 59, 59, 0, time.UTC)
+	a.now = func() time.Time { return now }
+	end := func(cpu time.Duration, rss int64, elapsed time.Duration, out int64) *Message {
+		return &Message{Kind: "end", Stats: &Stats{CPU: cpu, MaxRSS: rss, Elapsed: elapsed, StdoutBytes: out}}
+	}
+	a.Record("acme", end(time.Second, 2e9, 3*time.Second, 10))
+	a.Record("acme", end(2*time.Second, 1e9, time.Second, 5))
+	a.Record("zeta", end(0, 0, 0, 1))
+	a.Record("zeta", &Message{Kind: "stdout", Body: "x"})
+	a.Record("zeta", &Message{Kind: "end", Reason: "start-failed"})
+
+	us := a.Usage(time.Time{}, time.Time{})
+	if len(us) != 2 {
+		t.Fatalf("got %+v, want usage for two tenants", us)
+	}
+	if u := us[0]; u.Tenant != "acme" || u.Runs != 2 || u.CPUSeconds != 3 || u.GBSeconds != 7 || u.OutputBytes != 15 {
+		t.Errorf("got %+v for acme", u)
+	}
+	if u := us[1]; u.Tenant != "zeta" || u.Runs != 1 {
+		t.Errorf("got %+v for zeta", u)
+	}
+	if us := a.Usage(now.Add(time.Second), time.Time{}); len(us) != 0 {
+		t.Errorf("got %+v for the next window, want none", us)
+	}
+
+	rec := httptest.NewRecorder()
+	a.ServeHTTP(rec, httptest.NewRequest("GET", "/?from="+us[0].Start.Format(time.RFC3339), nil))
+	lines := strings.Split(strings.TrimSpace(rec.Body.String()), "\n")
+	if len(lines) != 3 || lines[0] != "tenant,window_start,runs,cpu_seconds,gb_seconds,output_bytes" ||
+		!strings.HasPrefix(lines[1], "acme,") || !strings.HasSuffix(lines[1], ",2,3,7,15") {
+		t.Errorf("got CSV\n%s", rec.Body)
+	}
+	rec = httptest.NewRecorder()
+	a.ServeHTTP(rec, httptest.NewRequest("GET", "/?to=yesterday", nil))
+	if rec.Code != 400 {
+		t.Errorf("got status %d for a bad time, want 400", rec.Code)
+	}
+
+	a.Forget(now.Add(time.Second))
+	if us := a.Usage(time.Time{}, time.Time{}); len(us) != 0 {
+		t.Errorf("got %+v after Forget, want none", us)
+	}
+}
+
+func TestAccountingTee(t *testing.T) {
+	unixTools(t)
+	a := NewAccounting(time.Minute)
+	o := make(chan *Message)
+	c := collect(o)
+	StartProcess(nil, []string{"echo", "hi"}, a.Tee("acme", o))
+	ms := <-c
+	if st := ms[len(ms)-1].Stats; st == nil || st.MaxRSS <= 0 {
+		t.Errorf("got Stats %+v, want the memory the program used", st)
+	}
+	if us := a.Usage(time.Time{}, time.Time{}); len(us) != 1 || us[0].Runs != 1 || us[0].OutputBytes != 3 {
+		t.Errorf("got %+v, want one run recorded", us)
+	}
+}
@@ -0,0 +1,161 @@
+// Copyright 2012 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package process
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// A Build runs a program in two phases, as the Go playground does: a
+// build step, such as "go build", and then, only if that succeeds, the
+// program it built. Both run in a new temporary directory, which is
+// removed once the Build ends. Messages carry the Build's id; the build
+// step's output is labelled "build", so that compiler errors arrive as
+// "stderr" Messages with that Label. The single "end" Message is the
+// program's, or, if the build step failed, the build step's with the
+// Reason "build-failed" and a Body starting with "build: ". A build step
+// that was killed or could not be started keeps its own Reason.
+type Build struct {
+	id   string
+	out  chan<- *Message
+	Done chan struct{} // closed once the "end" Message has been sent
+
+	mu     sync.Mutex
+	killed bool
+	proc   *Process // the phase running, if any
+}
+
+// StartBuild writes files, which map file names without a directory to
+// their contents, to a new temporary directory, and runs build and then
+// run there, overriding their Dir. run.Args[0] may name the built
+// program relative to the directory, as in "./prog".
+func StartBuild(files map[string]string, build, run *ProcessSpec, out chan<- *Message) (*Build, error) {
+	dir, err := ioutil.TempDir("", "build-")
+	if err != nil {
+		return nil, err
+	}
+	for name, data := range files {
+		if name != filepath.Base(name) || name == "." || name == ".." {
+			os.RemoveAll(dir)
+			return nil, errors.New("bad file name " + name)
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(data), 0600); err != nil {
+			os.RemoveAll(dir)
+			return nil, err
+		}
+	}
+	b := &Build{id: newID(), out: out, Done: make(chan struct{})}
+	bs, rs := *build, *run
+	bs.Dir, rs.Dir = dir, dir
+	go func() {
+		defer os.RemoveAll(dir)
+		b.run(&bs, &rs)
+	}()
+	return b, nil
+}
+
+// StartGoBuild builds the Go program src, a main package in one file,
+// and runs it with args, as StartBuild does.
+func StartGoBuild(src string, args []string, out chan<- *Message) (*Build, error) {
+	return StartBuild(map[string]string{"main.go": src},
+		&ProcessSpec{Args: []string{"go", "build", "-o", "prog", "main.go"}},
+		&ProcessSpec{Args: append([]string{"./prog"}, args...)},
+		out)
+}
+
+// Kill stops whichever phase is running, and waits for the Build to
+// finish.
+func (b *Build) Kill() {
+	b.mu.Lock()
+	b.killed = true
+	p := b.proc
+	b.mu.Unlock()
+	p.Kill()
+	<-b.Done
+}
+
+// Handle handles m, a Message from the client, as Process.Handle does
+// for the phase running, except that a "kill" Message kills the Build.
+func (b *Build) Handle(m *Message) error {
+	if m.Kind == "kill" {
+		b.Kill()
+		return nil
+	}
+	b.mu.Lock()
+	p := b.proc
+	b.mu.Unlock()
+	if p == nil {
+		return errors.New("process not running")
+	}
+	return p.Handle(m)
+}
+
+func (b *Build) run(build, run *ProcessSpec) {
+	defer close(b.Done)
+	m := b.phase(build, "build")
+	b.mu.Lock()
+	killed := b.killed
+	b.mu.Unlock()
+	switch {
+	case m.Body != "":
+		if m.Reason == "exited" || m.Reason == "signaled" {
+			m.Reason = "build-failed"
+		}
+		m.Body = "build: " + m.Body
+		b.out <- m
+		return
+	case killed:
+		// Killed just as the build step succeeded.
+		m.Reason = "killed"
+		b.out <- m
+		return
+	}
+	m.Release()
+	b.out <- b.phase(run, "")
+}
+
+// phase runs spec, relaying its output with the given Label, and returns
+// its "end" Message.
+func (b *Build) phase(spec *ProcessSpec, label string) *Message {
+	ch := make(chan *Message)
+	p := newProcess(ch)
+	registered := make(chan struct{})
+	go func() {
+		defer close(registered)
+		if p.launch(context.Background(), spec) != nil {
+			b.mu.Lock()
+			b.proc = p
+			kill := b.killed
+			b.mu.Unlock()
+			if kill {
+				go p.Kill()
+			}
+		}
+	}()
+	for m := range ch {
+		m.Id = b.id
+		if m.Kind == "end" {
+			<-registered
+			b.mu.Lock()
+			b.proc = nil
+			b.mu.Unlock()
+			return m
+		}
+		if label != "" {
+			if m.Label == "" {
+				m.Label = label
+			} else {
+				m.Label = label + "/" + m.Label
+			}
+		}
+		b.out <- m
+	}
+	panic("unreachable") // every Process ends with an "end" Message
+}
@@ -0,0 +1,98 @@
+package process
+
+import (
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+)
+
+// buildStep is a build step that "compiles" src.txt into prog, a shell
+// script, or fails with a compiler-like error if it holds "error".
+var buildStep = &ProcessSpec{Args: []string{"sh", "-c", `
+if grep -q error src.txt; then echo "src.txt:1: syntax error" >&2; exit 1; fi
+{ echo '#!/bin/sh'; cat src.txt; } > prog && chmod +x prog`}}
+
+func TestBuild(t *testing.T) {
+	unixTools(t)
+	out := make(chan *Message)
+	ms := collect(out)
+	b, err := StartBuild(map[string]string{"src.txt": "echo hello \"$1\""},
+		buildStep, &ProcessSpec{Args: []string{"./prog", "world"}}, out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := <-ms
+	<-b.Done
+	end := got[len(got)-1]
+	if len(got) != 2 || got[0].Body != "hello world\n" || got[0].Label != "" || got[0].Id != b.id {
+		t.Errorf("got %d Messages, first %+v; want hello world from the program", len(got), got[0])
+	}
+	if end.Body != "" || end.Reason != "exited" {
+		t.Errorf("end %+v", end)
+	}
+}
+
+func TestBuildFailed(t *testing.T) {
+	unixTools(t)
+	out := make(chan *Message)
+	ms := collect(out)
+	b, err := StartBuild(map[string]string{"src.txt": "error"},
+		buildStep, &ProcessSpec{Args: []string{"./prog"}}, out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := <-ms
+	<-b.Done
+	if len(got) != 2 || got[0].Kind != "stderr" || got[0].Label != "build" || !strings.Contains(got[0].Body, "syntax error") {
+		t.Errorf("got %d Messages, first %+v; want the compiler error", len(got), got[0])
+	}
+	end := got[len(got)-1]
+	if end.Reason != "build-failed" || end.Body != "build: exit status 1" || end.ExitCode != 1 {
+		t.Errorf("end %+v", end)
+	}
+}
+
+func TestBuildKill(t *testing.T) {
+	unixTools(t)
+	out := make(chan *Message)
+	ms := collect(out)
+	b, err := StartBuild(nil, &ProcessSpec{Args: []string{"sleep", "10"}}, &ProcessSpec{Args: []string{"true"}}, out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.AfterFunc(100*time.Millisecond, b.Kill)
+	got := <-ms
+	if end := got[len(got)-1]; end.Reason != "killed" || !strings.HasPrefix(end.Body, "build: ") {
+		t.Errorf("end %+v, want the build step killed", end)
+	}
+	<-b.Done
+	if _, err := StartBuild(map[string]string{"../x": ""}, buildStep, buildStep, out); err == nil {
+		t.Error("StartBuild wrote a file outside its directory")
+	}
+}
+
+func TestGoBuild(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds a Go program")
+	}
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go not found")
+	}
+	out := make(chan *Message)
+	ms := collect(out)
+	_, err := StartGoBuild("package main\n\nimport \"fmt\"\n\nfunc main() { fmt.Println(\"hi\") }\n", nil, out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stdout string
+	got := <-ms
+	for _, m := range got {
+		if m.Kind == "stdout" || m.Kind == "stderr" {
+			stdout += m.Label + ":" + m.Body
+		}
+	}
+	if end := got[len(got)-1]; stdout != ":hi\n" || end.Body != "" {
+		t.Errorf("output %q, end %+v", stdout, end)
+	}
+}
@@ -0,0 +1,27 @@
+// Copyright 2012 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package process
+
+// A Cgroup describes a cgroup v2 control group created for a single
+// Process. Unlike Limits, its limits apply to the program and every
+// process it starts taken together. The cgroup is removed, killing any
+// processes left in it, when the Process ends. Cgroups are only
+// supported on Linux.
+type Cgroup struct {
+	// Parent is the directory of the cgroup to create the Process' cgroup
+	// in, such as "/sys/fs/cgroup/playground". The server must be able
+	// to write to it, and its cgroup.subtree_control must enable the
+	// memory and cpu controllers for the limits to be set.
+	Parent string
+
+	// Memory is the memory use, in bytes, at which the kernel kills a
+	// process in the cgroup; the "end" Message then has Reason
+	// "oom-killed". Zero means no limit.
+	Memory int64
+
+	// CPUs is the number of CPUs' worth of time the cgroup may use, such
+	// as 0.5 or 2. Zero means no limit.
+	CPUs float64
+}
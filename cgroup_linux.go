@@ -0,0 +1,98 @@
+// Copyright 2012 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package process
+
+import (
+	"bufio"
+	"errors"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const cpuPeriod = 100000 // microseconds; the kernel's default for cpu.max
+
+// useCgroup creates a cgroup for the Process as cg describes and arranges
+// for cmd to start in it.
+func (p *Process) useCgroup(cmd *exec.Cmd, cg *Cgroup) error {
+	// The pid tells servers on this host apart, whatever their InstanceID.
+	_, id := SplitID(p.id)
+	dir := filepath.Join(cg.Parent, "process-"+strconv.Itoa(os.Getpid())+"-"+id)
+	if err := os.Mkdir(dir, 0755); err != nil {
+		return errors.New("creating cgroup: " + err.Error())
+	}
+	p.onRelease(func() { p.removeCgroup(dir) })
+	set := func(file, value string) error {
+		if err := ioutil.WriteFile(filepath.Join(dir, file), []byte(value), 0); err != nil {
+			return errors.New("setting cgroup " + file + ": " + err.Error())
+		}
+		return nil
+	}
+	if cg.Memory > 0 {
+		if err := set("memory.max", strconv.FormatInt(cg.Memory, 10)); err != nil {
+			return err
+		}
+	}
+	if cg.CPUs > 0 {
+		quota := int(cg.CPUs * cpuPeriod)
+		if err := set("cpu.max", strconv.Itoa(quota)+" "+strconv.Itoa(cpuPeriod)); err != nil {
+			return err
+		}
+	}
+	f, err := os.Open(dir)
+	if err != nil {
+		return errors.New("opening cgroup: " + err.Error())
+	}
+	p.onRelease(func() { f.Close() })
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = new(syscall.SysProcAttr)
+	}
+	cmd.SysProcAttr.UseCgroupFD = true
+	cmd.SysProcAttr.CgroupFD = int(f.Fd())
+	return nil
+}
+
+// removeCgroup records whether the cgroup in dir saw an OOM kill, then
+// kills any processes left in it and removes it.
+func (p *Process) removeCgroup(dir string) {
+	if oomKills(dir) > 0 {
+		p.mu.Lock()
+		p.oomKilled = true
+		p.mu.Unlock()
+	}
+	ioutil.WriteFile(filepath.Join(dir, "cgroup.kill"), []byte("1"), 0)
+	var err error
+	for i := 0; i < 50; i++ {
+		// Killed processes leave the cgroup once they have exited.
+		if err = os.Remove(dir); err == nil {
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	p.fail(errors.New("removing cgroup: " + err.Error()))
+}
+
+// oomKills returns the oom_kill count in the memory.events file of the
+// cgroup in dir.
+func oomKills(dir string) int {
+	f, err := os.Open(filepath.Join(dir, "memory.events"))
+	if err != nil {
+		return 0
+	}
+	defer f.Close()
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		if v, ok := strings.CutPrefix(s.Text(), "oom_kill "); ok {
+			n, _ := strconv.Atoi(v)
+			return n
+		}
+	}
+	return 0
+}
@@ -0,0 +1,68 @@
+package process
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testCgroup returns a cgroup v2 directory the test may create cgroups
+// in, or skips the test if there is none with the memory controller.
+func testCgroup(t *testing.T) string {
+	root := "/sys/fs/cgroup"
+	b, err := ioutil.ReadFile(filepath.Join(root, "cgroup.subtree_control"))
+	if err != nil || !strings.Contains(string(b), "memory") {
+		t.Skip("no cgroup v2 hierarchy with the memory controller")
+	}
+	dir, err := ioutil.TempDir(root, "process-test-")
+	if err != nil {
+		t.Skip(err)
+	}
+	t.Cleanup(func() { os.Remove(dir) })
+	ioutil.WriteFile(filepath.Join(dir, "cgroup.subtree_control"), []byte("+memory +cpu"), 0)
+	return dir
+}
+
+func TestCgroup(t *testing.T) {
+	parent := testCgroup(t)
+	end := func(args ...string) []*Message {
+		o := make(chan *Message)
+		c := collect(o)
+		StartProcessSpec(&ProcessSpec{
+			Args:   args,
+			Cgroup: &Cgroup{Parent: parent, Memory: 32 << 20, CPUs: 0.5},
+		}, o)
+		return <-c
+	}
+	ms := end("sh", "-c", `cat "/sys/fs/cgroup$(cut -d: -f3 /proc/self/cgroup)/memory.max"`)
+	if ms[0].Body != "33554432\n" {
+		t.Errorf("got %+v, want memory.max set", ms)
+	}
+	// tail holds its whole input while looking for the last line.
+	ms = end("sh", "-c", "head -c 256m /dev/zero | tail -c 1")
+	if m := ms[len(ms)-1]; m.Reason != "oom-killed" {
+		t.Errorf("got %+v, want Reason oom-killed", m)
+	}
+	if fs, _ := ioutil.ReadDir(parent); len(fs) > 0 {
+		for _, f := range fs {
+			if f.IsDir() {
+				t.Errorf("cgroup %s left behind", f.Name())
+			}
+		}
+	}
+}
+
+func TestCgroupInstanceID(t *testing.T) {
+	parent := testCgroup(t)
+	defer func(s string) { InstanceID = s }(InstanceID)
+	InstanceID = "web-1"
+	o := make(chan *Message)
+	c := collect(o)
+	StartProcessSpec(&ProcessSpec{Args: []string{"true"}, Cgroup: &Cgroup{Parent: parent}}, o)
+	ms := <-c
+	if m := ms[len(ms)-1]; m.Body != "" || m.Reason != "exited" {
+		t.Errorf("got %+v, want the program run in a cgroup", m)
+	}
+}
@@ -0,0 +1,16 @@
+// Copyright 2012 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !linux
+
+package process
+
+import (
+	"errors"
+	"os/exec"
+)
+
+func (p *Process) useCgroup(cmd *exec.Cmd, cg *Cgroup) error {
+	return errors.New("cgroups are not supported on this system")
+}
@@ -0,0 +1,135 @@
+// Copyright 2012 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package process
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A Cron is a parsed cron expression, which matches a set of minutes.
+type Cron struct {
+	minute, hour, dom, month, dow uint64 // bit i set if value i matches
+	anyDay                        bool   // dom or dow is "*"
+}
+
+var cronNicknames = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// ParseCron parses a cron expression with the five standard fields,
+// minute, hour, day of month, month and day of week, each a "*" or a
+// comma-separated list of numbers and ranges such as "1-5", optionally
+// with a step such as "*/15". Sunday is day 0 or 7. As in cron, when both
+// days are restricted a time matches if either does. The nicknames
+// "@hourly", "@daily", "@weekly", "@monthly" and "@yearly" are accepted
+// too.
+func ParseCron(expr string) (*Cron, error) {
+	if s, ok := cronNicknames[expr]; ok {
+		expr = s
+	}
+	f := strings.Fields(expr)
+	if len(f) != 5 {
+		return nil, errors.New("cron expression " + strconv.Quote(expr) + " does not have 5 fields")
+	}
+	c := new(Cron)
+	for i, field := range []struct {
+		bits     *uint64
+		min, max int
+	}{
+		{&c.minute, 0, 59},
+		{&c.hour, 0, 23},
+		{&c.dom, 1, 31},
+		{&c.month, 1, 12},
+		{&c.dow, 0, 7},
+	} {
+		bits, err := cronField(f[i], field.min, field.max)
+		if err != nil {
+			return nil, errors.New("cron expression " + strconv.Quote(expr) + ": " + err.Error())
+		}
+		*field.bits = bits
+	}
+	if c.dow&(1<<7) != 0 {
+		c.dow |= 1 // Sunday
+	}
+	c.anyDay = f[2] == "*" || f[4] == "*"
+	return c, nil
+}
+
+// cronField parses one field of a cron expression, whose values range
+// from min to max.
+func cronField(s string, min, max int) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(s, ",") {
+		step := 1
+		if i := strings.IndexByte(part, '/'); i >= 0 {
+			n, err := strconv.Atoi(part[i+1:])
+			if err != nil || n < 1 {
+				return 0, errors.New("bad step in " + strconv.Quote(part))
+			}
+			step, part = n, part[:i]
+		}
+		lo, hi := min, max
+		if part != "*" {
+			var err error
+			if i := strings.IndexByte(part, '-'); i >= 0 {
+				lo, err = strconv.Atoi(part[:i])
+				if err == nil {
+					hi, err = strconv.Atoi(part[i+1:])
+				}
+			} else {
+				lo, err = strconv.Atoi(part)
+				hi = lo
+			}
+			if err != nil || lo < min || hi > max || lo > hi {
+				return 0, errors.New("bad value " + strconv.Quote(part))
+			}
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+// Next returns the first minute after t that c matches, in t's location,
+// or the zero Time if there is none within five years.
+func (c *Cron) Next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		switch {
+		case c.month&(1<<uint(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+		case !c.day(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		case c.hour&(1<<uint(t.Hour())) == 0:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+		case c.minute&(1<<uint(t.Minute())) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// day reports whether c matches the day of t.
+func (c *Cron) day(t time.Time) bool {
+	dom := c.dom&(1<<uint(t.Day())) != 0
+	dow := c.dow&(1<<uint(t.Weekday())) != 0
+	if c.anyDay {
+		return dom && dow
+	}
+	return dom || dow
+}
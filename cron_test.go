@@ -0,0 +1,44 @@
+package process
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCron(t *testing.T) {
+	base := time.Date(2012, 11, 7, 10, 30, 15, 0, time.UTC) // a Wednesday
+	for _, tt := range []struct {
+		expr string
+		next string
+	}{
+		{"* * * * *", "2012-11-07 10:31"},
+		{"*/15 * * * *", "2012-11-07 10:45"},
+		{"0 9-17 * * 1-5", "2012-11-07 11:00"},
+		{"0 0 * * 0", "2012-11-11 00:00"},
+		{"0 0 * * 7", "2012-11-11 00:00"},
+		{"5,10 3 1 * *", "2012-12-01 03:05"},
+		{"0 0 13 * 5", "2012-11-09 00:00"}, // Friday or the 13th
+		{"0 0 29 2 *", "2016-02-29 00:00"},
+		{"0 0 30 2 *", ""},
+		{"@monthly", "2012-12-01 00:00"},
+	} {
+		c, err := ParseCron(tt.expr)
+		if err != nil {
+			t.Errorf("ParseCron(%q): %v", tt.expr, err)
+			continue
+		}
+		next := c.Next(base)
+		got := ""
+		if !next.IsZero() {
+			got = next.Format("2006-01-02 15:04")
+		}
+		if got != tt.next {
+			t.Errorf("%q: Next = %q, want %q", tt.expr, got, tt.next)
+		}
+	}
+	for _, expr := range []string{"* * * *", "60 * * * *", "* * 0 * *", "*/0 * * * *", "5-1 * * * *", "a * * * *"} {
+		if _, err := ParseCron(expr); err == nil {
+			t.Errorf("ParseCron(%q) succeeded", expr)
+		}
+	}
+}
@@ -0,0 +1,215 @@
+// Copyright 2012 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package process
+
+import (
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// Docker is a Runner that runs each program in a new container, removed
+// when the program ends, using the docker command-line client.
+//
+// spec.Args is the command run in the container and spec.Dir, if set,
+// its working directory there. spec.User sets the container's user.
+// spec.Limits is not supported.
+type Docker struct {
+	Image  string  // image to run, such as "golang:1.21"
+	Mounts []Mount // host paths to mount in the container
+
+	// Network is the container's network, such as "bridge" or "host".
+	// If empty, the container has no network.
+	Network string
+
+	// Command is the docker client to run. If empty, it is "docker".
+	Command string
+}
+
+// A Mount makes the host path Source available in a container at Target.
+type Mount struct {
+	Source   string
+	Target   string
+	ReadOnly bool
+}
+
+// Start starts the program of spec in a new container.
+func (d Docker) Start(spec *ProcessSpec, env []string, stdin io.Reader, stdout, stderr io.Writer) (Run, error) {
+	if d.Image == "" {
+		return nil, errors.New("Docker.Image is empty")
+	}
+	if !spec.Limits.zero() {
+		return nil, errors.New("Limits are not supported by the Docker runner")
+	}
+	name, err := runName()
+	if err != nil {
+		return nil, err
+	}
+	network := d.Network
+	if network == "" {
+		network = "none"
+	}
+	args := []string{"run", "--rm", "--name", name, "--network", network}
+	for _, m := range d.Mounts {
+		if strings.Contains(m.Source, ",") || strings.Contains(m.Target, ",") {
+			return nil, errors.New("mount path contains a comma: " + m.Source + ":" + m.Target)
+		}
+		opt := "type=bind,source=" + m.Source + ",target=" + m.Target
+		if m.ReadOnly {
+			opt += ",readonly"
+		}
+		args = append(args, "--mount", opt)
+	}
+	if spec.Dir != "" {
+		args = append(args, "--workdir", spec.Dir)
+	}
+	if u := spec.User; u != nil {
+		args = append(args, "--user", strconv.Itoa(int(u.Uid))+":"+strconv.Itoa(int(u.Gid)))
+		for _, g := range u.Groups {
+			args = append(args, "--group-add", strconv.Itoa(int(g)))
+		}
+	}
+	// Name the variables only, so that docker copies their values from
+	// its own environment and secrets do not appear in its arguments.
+	for _, kv := range env {
+		if i := strings.Index(kv, "="); i > 0 {
+			args = append(args, "--env", kv[:i])
+		}
+	}
+	if stdin != nil {
+		args = append(args, "--interactive")
+	}
+	args = append(args, "--", d.Image)
+	args = append(args, spec.Args...)
+
+	r := &dockerRun{d: d, name: name, stderr: &headWriter{w: stderr}}
+	r.cmd = exec.Command(d.command(), args...)
+	r.cmd.Env = append(os.Environ(), env...)
+	r.cmd.Stdout, r.cmd.Stderr = stdout, r.stderr
+	if stdin != nil {
+		// Copy stdin here rather than leaving it to exec, whose Wait
+		// would otherwise wait for more input after the program ended.
+		w, err := r.cmd.StdinPipe()
+		if err != nil {
+			return nil, err
+		}
+		go func() {
+			io.Copy(w, stdin)
+			w.Close()
+		}()
+	}
+	if err := r.cmd.Start(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (d Docker) command() string {
+	if d.Command == "" {
+		return "docker"
+	}
+	return d.Command
+}
+
+// dockerRun is a program running in a container.
+type dockerRun struct {
+	d      Docker
+	name   string
+	cmd    *exec.Cmd
+	stderr *headWriter
+}
+
+// ID returns the container's name.
+func (r *dockerRun) ID() string {
+	return r.name
+}
+
+// Wait waits for the docker client to exit. The client exits with the
+// program's exit status, which for a program terminated by a signal is
+// 128 plus the signal's number, so such statuses are reported as that
+// signal, as a shell would. If docker itself fails it exits with status
+// 125, after saying why on stderr in a line starting "docker: ".
+func (r *dockerRun) Wait() (ExitStatus, error) {
+	err := r.cmd.Wait()
+	if _, ok := err.(*exec.ExitError); !ok && err != nil {
+		return ExitStatus{}, err
+	}
+	code := r.cmd.ProcessState.ExitCode()
+	if code == 125 && strings.HasPrefix(string(r.stderr.head), "docker: ") {
+		return ExitStatus{}, errors.New("docker run failed")
+	}
+	if name := containerSignal(code); name != "" {
+		return ExitStatus{Code: -1, Signal: name}, nil
+	}
+	return ExitStatus{Code: code}, nil
+}
+
+// linuxSignals names the signals a container's program may be terminated
+// by, by number, whatever the server's own system.
+var linuxSignals = [...]string{
+	1: "SIGHUP", 2: "SIGINT", 3: "SIGQUIT", 4: "SIGILL", 5: "SIGTRAP",
+	6: "SIGABRT", 7: "SIGBUS", 8: "SIGFPE", 9: "SIGKILL", 10: "SIGUSR1",
+	11: "SIGSEGV", 12: "SIGUSR2", 13: "SIGPIPE", 14: "SIGALRM", 15: "SIGTERM",
+}
+
+// containerSignal returns the name of the signal that exit status code
+// stands for, or "" if it is an ordinary exit status.
+func containerSignal(code int) string {
+	n := code - 128
+	switch {
+	case n <= 0 || n >= 32:
+		return ""
+	case n < len(linuxSignals):
+		return linuxSignals[n]
+	}
+	return "signal " + strconv.Itoa(n)
+}
+
+// headWriter passes writes on to w, keeping the first bytes written.
+type headWriter struct {
+	w    io.Writer
+	head []byte
+}
+
+func (hw *headWriter) Write(b []byte) (int, error) {
+	if n := 256 - len(hw.head); n > 0 {
+		if n > len(b) {
+			n = len(b)
+		}
+		hw.head = append(hw.head, b[:n]...)
+	}
+	return hw.w.Write(b)
+}
+
+// Signal sends sig to the container's main process.
+func (r *dockerRun) Signal(sig os.Signal) error {
+	name, ok := nameOfSignal(sig)
+	if !ok {
+		return errors.New("unknown signal: " + sig.String())
+	}
+	return r.kill(name)
+}
+
+// Kill kills the container, and with it every process in it.
+func (r *dockerRun) Kill() error {
+	if err := r.kill("SIGKILL"); err != nil {
+		// The container may not exist yet; make sure the client at
+		// least stops waiting for it.
+		r.cmd.Process.Kill()
+		return err
+	}
+	return nil
+}
+
+func (r *dockerRun) kill(signal string) error {
+	out, err := exec.Command(r.d.command(), "kill", "--signal", signal, r.name).CombinedOutput()
+	if err != nil {
+		return errors.New("docker kill: " + strings.TrimSpace(string(out)))
+	}
+	return nil
+}
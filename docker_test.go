@@ -0,0 +1,125 @@
+package process
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeDocker writes a docker client to dir that prints its arguments and
+// TOKEN for "docker run", exiting with status 4, unless dir/hang exists,
+// in which case it waits for "docker kill" and records its arguments.
+func fakeDocker(t *testing.T, dir string) string {
+	script := `#!/bin/sh
+dir=` + dir + `
+case "$1" in
+run)
+	if [ -e $dir/hang ]; then
+		while [ ! -e $dir/killed ]; do sleep 0.01; done
+		exit 137
+	fi
+	echo "$@"
+	echo "token=$TOKEN"
+	exit 4;;
+kill)
+	echo "$@" > $dir/killed.tmp && mv $dir/killed.tmp $dir/killed;;
+esac
+`
+	name := filepath.Join(dir, "docker")
+	if err := ioutil.WriteFile(name, []byte(script), 0700); err != nil {
+		t.Fatal(err)
+	}
+	return name
+}
+
+func TestDocker(t *testing.T) {
+	unixTools(t)
+	dir := t.TempDir()
+	d := Docker{
+		Image:   "alpine",
+		Mounts:  []Mount{{Source: "/src", Target: "/work", ReadOnly: true}},
+		Command: fakeDocker(t, dir),
+	}
+	o := make(chan *Message)
+	c := collect(o)
+	p := StartProcessSpec(&ProcessSpec{
+		Args:   []string{"ls", "-l"},
+		Dir:    "/work",
+		Env:    []string{"TOKEN=s3cr3t"},
+		User:   &User{Uid: 1000, Gid: 1000},
+		Runner: d,
+	}, o)
+	<-p.Done
+	var out string
+	ms := <-c
+	for _, m := range ms {
+		if m.Kind == "stdout" {
+			out += m.Body
+		}
+	}
+	for _, want := range []string{
+		"run --rm --name " + p.run.ID() + " ",
+		"--network none --mount type=bind,source=/src,target=/work,readonly --workdir /work --user 1000:1000 --env TOKEN -- alpine ls -l\n",
+		"token=s3cr3t\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output %q does not contain %q", out, want)
+		}
+	}
+	if strings.Count(out, "s3cr3t") != 1 {
+		t.Errorf("output %q passes the variable's value as an argument", out)
+	}
+	if m := ms[len(ms)-1]; m.ExitCode != 4 {
+		t.Errorf("got %+v, want exit status 4", m)
+	}
+
+	ioutil.WriteFile(filepath.Join(dir, "hang"), nil, 0600)
+	o = make(chan *Message)
+	go drain(o)
+	p = StartProcessSpec(&ProcessSpec{Args: []string{"sleep", "60"}, Runner: d}, o)
+	p.Kill()
+	b, _ := ioutil.ReadFile(filepath.Join(dir, "killed"))
+	if want := "kill --signal SIGKILL " + p.run.ID() + "\n"; string(b) != want {
+		t.Errorf("docker was run with %q, want %q", b, want)
+	}
+}
+
+func TestDockerExit(t *testing.T) {
+	unixTools(t)
+	dir := t.TempDir()
+	name := filepath.Join(dir, "docker")
+	// The fake runs its last argument, a shell command, as the program.
+	script := "#!/bin/sh\nfor a; do :; done\nsh -c \"$a\"\n"
+	if err := ioutil.WriteFile(name, []byte(script), 0700); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		cmd    string
+		code   int
+		signal string
+		failed bool
+	}{
+		{"exit 125", 125, "", false},
+		{"echo 'docker: Error response from daemon: no such image' >&2; exit 125", 0, "", true},
+		{"exit 137", -1, "SIGKILL", false},
+		{"exit 143", -1, "SIGTERM", false},
+		{"exit 3", 3, "", false},
+	} {
+		o := make(chan *Message)
+		c := collect(o)
+		p := StartProcessSpec(&ProcessSpec{Args: []string{tt.cmd}, Runner: Docker{Image: "alpine", Command: name}}, o)
+		ms := <-c
+		<-p.Done
+		m := ms[len(ms)-1]
+		if tt.failed {
+			if m.Body != "docker run failed" {
+				t.Errorf("%s: got %+v, want docker's failure reported", tt.cmd, m)
+			}
+			continue
+		}
+		if m.ExitCode != tt.code || m.Signal != tt.signal {
+			t.Errorf("%s: got ExitCode %d, Signal %q; want %d, %q", tt.cmd, m.ExitCode, m.Signal, tt.code, tt.signal)
+		}
+	}
+}
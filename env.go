@@ -0,0 +1,86 @@
+// Copyright 2012 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package process
+
+import (
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Locales lists the values allowed for ProcessSpec.Locale. A server may
+// change it before starting any Processes to match the locales installed.
+var Locales = []string{"C", "C.UTF-8", "POSIX", "en_US.UTF-8"}
+
+// checkLocale reports whether spec's Locale and TZ are allowed.
+func checkLocale(spec *ProcessSpec) error {
+	if spec.Locale != "" {
+		ok := false
+		for _, l := range Locales {
+			ok = ok || l == spec.Locale
+		}
+		if !ok {
+			return errors.New("locale " + strconv.Quote(spec.Locale) + " is not allowed")
+		}
+	}
+	if spec.TZ != "" {
+		// LoadLocation only accepts names from the time zone database,
+		// and "Local", which would mean nothing to the program.
+		if _, err := time.LoadLocation(spec.TZ); err != nil || spec.TZ == "Local" {
+			return errors.New("unknown time zone " + strconv.Quote(spec.TZ))
+		}
+	}
+	return nil
+}
+
+// environ returns the environment for a program run with spec: the
+// server's environment unless spec.ClearEnv is set, without the variables
+// matching spec.StripEnv, followed by specEnv(spec, extra). Later entries
+// override earlier ones.
+func environ(spec *ProcessSpec, extra []string) []string {
+	var env []string
+	if !spec.ClearEnv {
+		for _, kv := range os.Environ() {
+			if !stripped(kv, spec.StripEnv) {
+				env = append(env, kv)
+			}
+		}
+	}
+	return append(env, specEnv(spec, extra)...)
+}
+
+// specEnv returns the environment entries spec adds for a program:
+// spec.Env, the variables for spec.Locale and spec.TZ, and then extra.
+func specEnv(spec *ProcessSpec, extra []string) []string {
+	env := append([]string(nil), spec.Env...)
+	if spec.Locale != "" {
+		env = append(env, "LANG="+spec.Locale, "LC_ALL="+spec.Locale)
+	}
+	if spec.TZ != "" {
+		env = append(env, "TZ="+spec.TZ)
+	}
+	return append(env, extra...)
+}
+
+// stripped reports whether the "NAME=value" entry kv is matched by one of
+// patterns, which are variable names or name prefixes ending in "*".
+func stripped(kv string, patterns []string) bool {
+	name := kv
+	if i := strings.Index(kv, "="); i >= 0 {
+		name = kv[:i]
+	}
+	for _, pat := range patterns {
+		if prefix := strings.TrimSuffix(pat, "*"); prefix != pat {
+			if strings.HasPrefix(name, prefix) {
+				return true
+			}
+		} else if name == pat {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,66 @@
+package process
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestEnviron(t *testing.T) {
+	unixTools(t)
+	os.Setenv("PROCESS_TEST_SECRET", "x")
+	os.Setenv("PROCESS_TEST_KEEP", "y")
+	defer os.Unsetenv("PROCESS_TEST_SECRET")
+	defer os.Unsetenv("PROCESS_TEST_KEEP")
+
+	for _, tt := range []struct {
+		spec ProcessSpec
+		want string
+	}{
+		{ProcessSpec{}, "x y"},
+		{ProcessSpec{StripEnv: []string{"PROCESS_TEST_SECRET"}}, " y"},
+		{ProcessSpec{StripEnv: []string{"PROCESS_TEST_*"}}, " "},
+		{ProcessSpec{Env: []string{"PROCESS_TEST_KEEP=z"}}, "x z"},
+		{ProcessSpec{ClearEnv: true, Env: []string{"PROCESS_TEST_KEEP=z"}}, " z"},
+	} {
+		spec := tt.spec
+		spec.Args = []string{"/bin/sh", "-c", `echo "$PROCESS_TEST_SECRET $PROCESS_TEST_KEEP"`}
+		o := make(chan *Message)
+		c := collect(o)
+		p := StartProcessSpec(&spec, o)
+		<-p.Done
+		if got := strings.TrimSuffix((<-c)[0].Body, "\n"); got != tt.want {
+			t.Errorf("%+v: got %q, want %q", tt.spec, got, tt.want)
+		}
+	}
+}
+
+func TestLocale(t *testing.T) {
+	unixTools(t)
+	o := make(chan *Message)
+	c := collect(o)
+	p := StartProcessSpec(&ProcessSpec{
+		Args:   []string{"sh", "-c", `echo "$LANG $LC_ALL $TZ"`},
+		Env:    []string{"LANG=xx_XX", "TZ=Mars/Olympus"},
+		Locale: "C.UTF-8",
+		TZ:     "Asia/Tokyo",
+	}, o)
+	<-p.Done
+	if got := (<-c)[0].Body; got != "C.UTF-8 C.UTF-8 Asia/Tokyo\n" {
+		t.Errorf("got %q, want the Locale and TZ to override Env", got)
+	}
+
+	for _, spec := range []ProcessSpec{
+		{Args: []string{"true"}, Locale: "xx_XX.UTF-8"},
+		{Args: []string{"true"}, TZ: "../../etc/passwd"},
+		{Args: []string{"true"}, TZ: "Local"},
+	} {
+		o := make(chan *Message)
+		c := collect(o)
+		if p := StartProcessSpec(&spec, o); p != nil {
+			t.Errorf("%+v: started, want it rejected", spec)
+			p.Kill()
+		}
+		<-c
+	}
+}
@@ -0,0 +1,97 @@
+// Copyright 2012 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package process
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+// An Event is one line of an event log. Each line is a JSON object:
+//
+//	{"seq":1,"time":"2012-11-07T10:00:00.123Z","type":"stdout","id":"3","body":"hello\n"}
+//
+// seq counts from 1 in each log and has no gaps, time is when the Message
+// was logged in RFC 3339 format, and type is the Message Kind. The other
+// fields are copied from the Message and omitted when empty.
+type Event struct {
+	Seq         uint64    `json:"seq"`
+	Time        time.Time `json:"time"`
+	Type        string    `json:"type"`
+	Id          string    `json:"id"`
+	Label       string    `json:"label,omitempty"`
+	Body        string    `json:"body,omitempty"`
+	Progress    *Progress `json:"progress,omitempty"`
+	ExitCode    int       `json:"exit_code,omitempty"`
+	Signal      string    `json:"signal,omitempty"`
+	Reason      string    `json:"reason,omitempty"`
+	Termination string    `json:"termination,omitempty"`
+	Stats       *Stats    `json:"stats,omitempty"`
+	Leaked      int       `json:"leaked,omitempty"`
+	FDs         *FDStats  `json:"fds,omitempty"`
+	Fingerprint string    `json:"fingerprint,omitempty"`
+	ExitCodes   []int     `json:"exit_codes,omitempty"`
+}
+
+// EventLog writes Messages as Events in JSON Lines format, for consumers
+// such as analytics pipelines. It is safe for concurrent use.
+type EventLog struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+	seq uint64
+}
+
+// NewEventLog returns an EventLog writing to w.
+func NewEventLog(w io.Writer) *EventLog {
+	return &EventLog{enc: json.NewEncoder(w)}
+}
+
+// Log writes m to the log as the next Event.
+func (l *EventLog) Log(m *Message) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.seq++
+	return l.enc.Encode(&Event{
+		Seq:         l.seq,
+		Time:        time.Now().UTC(),
+		Type:        m.Kind,
+		Id:          m.Id,
+		Label:       m.Label,
+		Body:        m.Body,
+		Progress:    m.Progress,
+		ExitCode:    m.ExitCode,
+		Signal:      m.Signal,
+		Reason:      m.Reason,
+		Termination: m.Termination,
+		Stats:       m.Stats,
+		Leaked:      m.Leaked,
+		FDs:         m.FDs,
+		Fingerprint: m.Fingerprint,
+		ExitCodes:   m.ExitCodes,
+	})
+}
+
+// Tee returns a channel that wraps dest. Messages sent to the channel are
+// logged to l and then sent to dest, until an "end" Message has been
+// passed on. Errors writing the log do not stop Messages reaching dest.
+// If the channel is closed, as it is after the "end" Message of a
+// Process that could not be started, dest is closed too.
+func (l *EventLog) Tee(dest chan<- *Message) chan<- *Message {
+	ch := make(chan *Message)
+	go func() {
+		for m := range ch {
+			l.Log(m)
+			dest <- m
+			if m.Kind == "end" && m.Stats != nil {
+				// The program ran, so the channel is left open.
+				return
+			}
+		}
+		close(dest)
+	}()
+	return ch
+}
@@ -0,0 +1,79 @@
+package process
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestEventLog(t *testing.T) {
+	unixTools(t)
+	var buf bytes.Buffer
+	l := NewEventLog(&buf)
+	o := make(chan *Message)
+	c := collect(o)
+	p := StartProcess(nil, []string{"echo", "hi"}, l.Tee(o))
+	<-p.Done
+	<-c
+
+	var evs []Event
+	s := bufio.NewScanner(&buf)
+	for s.Scan() {
+		var ev Event
+		if err := json.Unmarshal(s.Bytes(), &ev); err != nil {
+			t.Fatalf("bad line %q: %v", s.Text(), err)
+		}
+		evs = append(evs, ev)
+	}
+	if len(evs) != 2 {
+		t.Fatalf("got %d events, want 2:\n%s", len(evs), buf.String())
+	}
+	for i, want := range []Event{{Seq: 1, Type: "stdout", Body: "hi\n"}, {Seq: 2, Type: "end"}} {
+		ev := evs[i]
+		if ev.Seq != want.Seq || ev.Type != want.Type || ev.Body != want.Body || ev.Id != p.id || ev.Time.IsZero() {
+			t.Errorf("event %d = %+v, want %+v", i, ev, want)
+		}
+	}
+}
+
+func TestEventLogTeeClose(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewEventLog(&buf)
+	o := make(chan *Message)
+	if p := StartProcess(nil, []string{"./does-not-exist"}, l.Tee(o)); p != nil {
+		t.Fatal("started a program that does not exist")
+	}
+	var n int
+	for range o {
+		n++
+	}
+	if n != 1 || bytes.Count(buf.Bytes(), []byte("\n")) != 1 {
+		t.Errorf("got %d Messages and log\n%s, want just the end", n, buf.String())
+	}
+}
+
+func TestEventLogProgress(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewEventLog(&buf)
+	l.Log(&Message{Id: "1", Kind: "progress", Body: "50%", Progress: &Progress{Done: 50, Total: 100, Unit: "%"}})
+	var ev Event
+	if err := json.Unmarshal(buf.Bytes(), &ev); err != nil {
+		t.Fatal(err)
+	}
+	if ev.Progress == nil || ev.Progress.Done != 50 || ev.Progress.Unit != "%" {
+		t.Errorf("event %+v lost the progress", ev)
+	}
+}
+
+func TestEventLogExitCodes(t *testing.T) {
+	var buf bytes.Buffer
+	NewEventLog(&buf).Log(&Message{Id: "1", Kind: "end", ExitCodes: []int{0, NotStarted, 2}})
+	var ev Event
+	if err := json.Unmarshal(buf.Bytes(), &ev); err != nil {
+		t.Fatal(err)
+	}
+	if len(ev.ExitCodes) != 3 || ev.ExitCodes[1] != NotStarted || ev.ExitCodes[2] != 2 {
+		t.Errorf("event %+v lost the exit codes", ev)
+	}
+}
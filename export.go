@@ -0,0 +1,120 @@
+// Copyright 2012 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package process
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"strconv"
+)
+
+type junitSuite struct {
+	XMLName  xml.Name    `xml:"testsuite"`
+	Name     string      `xml:"name,attr"`
+	Tests    int         `xml:"tests,attr"`
+	Failures int         `xml:"failures,attr"`
+	Skipped  int         `xml:"skipped,attr"`
+	Time     string      `xml:"time,attr"`
+	Cases    []junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+	Name    string        `xml:"name,attr"`
+	Class   string        `xml:"classname,attr"`
+	Time    string        `xml:"time,attr"`
+	Failure *junitFailure `xml:"failure,omitempty"`
+	Skipped *struct{}     `xml:"skipped,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// WriteJUnit writes r to w as a JUnit XML test suite called name, with a
+// test case for each step.
+func (r *Result) WriteJUnit(w io.Writer, name string) error {
+	s := r.Summary()
+	suite := junitSuite{
+		Name:     name,
+		Tests:    len(r.Steps),
+		Failures: s.Failed,
+		Skipped:  s.Skipped,
+		Time:     strconv.FormatFloat(r.Duration.Seconds(), 'f', 3, 64),
+	}
+	for _, st := range r.Steps {
+		c := junitCase{
+			Name:  st.Label,
+			Class: name,
+			Time:  strconv.FormatFloat(st.Duration.Seconds(), 'f', 3, 64),
+		}
+		switch {
+		case st.Skipped:
+			c.Skipped = &struct{}{}
+		case st.Failed():
+			c.Failure = &junitFailure{
+				Message: st.Err,
+				Text:    "exit code " + strconv.Itoa(st.ExitCode),
+			}
+		}
+		suite.Cases = append(suite.Cases, c)
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "\t")
+	if err := enc.Encode(suite); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+type sarifLog struct {
+	Version string     `json:"version"`
+	Schema  string     `json:"$schema"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool struct {
+		Driver struct {
+			Name string `json:"name"`
+		} `json:"driver"`
+	} `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifResult struct {
+	RuleId  string `json:"ruleId"`
+	Level   string `json:"level"`
+	Message struct {
+		Text string `json:"text"`
+	} `json:"message"`
+}
+
+// WriteSARIF writes r to w as a SARIF 2.1.0 log for the named tool, with
+// an error result for each failed step.
+func (r *Result) WriteSARIF(w io.Writer, tool string) error {
+	run := sarifRun{Results: []sarifResult{}}
+	run.Tool.Driver.Name = tool
+	for _, st := range r.Steps {
+		if !st.Failed() {
+			continue
+		}
+		res := sarifResult{RuleId: "step-failed", Level: "error"}
+		res.Message.Text = st.Label + ": " + st.Err
+		run.Results = append(run.Results, res)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "\t")
+	return enc.Encode(sarifLog{
+		Version: "2.1.0",
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Runs:    []sarifRun{run},
+	})
+}
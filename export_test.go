@@ -0,0 +1,52 @@
+package process
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+var exportResult = &Result{
+	Steps: []StepResult{
+		{Label: "a", ExitCode: 0, Duration: time.Second},
+		{Label: "b", ExitCode: 2, Err: "exit status 2", Duration: 1500 * time.Millisecond},
+		{Label: "c", ExitCode: -1, Skipped: true},
+	},
+	Duration: 3 * time.Second,
+}
+
+func TestWriteJUnit(t *testing.T) {
+	var buf bytes.Buffer
+	if err := exportResult.WriteJUnit(&buf, "build"); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`<testsuite name="build" tests="3" failures="1" skipped="1" time="3.000">`,
+		`<testcase name="a" classname="build" time="1.000"></testcase>`,
+		`<failure message="exit status 2">exit code 2</failure>`,
+		`<skipped></skipped>`,
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("JUnit output lacks %s:\n%s", want, buf.String())
+		}
+	}
+}
+
+func TestWriteSARIF(t *testing.T) {
+	var buf bytes.Buffer
+	if err := exportResult.WriteSARIF(&buf, "process"); err != nil {
+		t.Fatal(err)
+	}
+	var log sarifLog
+	if err := json.Unmarshal(buf.Bytes(), &log); err != nil {
+		t.Fatal(err)
+	}
+	if log.Version != "2.1.0" || len(log.Runs) != 1 || len(log.Runs[0].Results) != 1 {
+		t.Fatalf("unexpected SARIF log:\n%s", buf.String())
+	}
+	if got := log.Runs[0].Results[0].Message.Text; got != "b: exit status 2" {
+		t.Errorf("result message = %q", got)
+	}
+}
@@ -0,0 +1,49 @@
+// Copyright 2012 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package process
+
+import (
+	"sync"
+	"time"
+)
+
+// Faults describes failures to inject into Processes so that callers and
+// tests can exercise their error paths. The zero value injects nothing.
+type Faults struct {
+	StartErr   error         // returned in place of starting the command
+	WriteDelay time.Duration // sleep before each output Message is sent
+	DropEvery  int           // drop every nth output Message; 0 drops none
+	KillDelay  time.Duration // sleep before Kill signals the child
+}
+
+var faults struct {
+	sync.Mutex
+	f *Faults
+}
+
+// InjectFaults makes Processes started from now on misbehave as described
+// by f, and returns a function that restores the previous setting. It is
+// meant for tests; a nil f turns fault injection off.
+func InjectFaults(f *Faults) (restore func()) {
+	faults.Lock()
+	prev := faults.f
+	faults.f = f
+	faults.Unlock()
+	return func() {
+		faults.Lock()
+		faults.f = prev
+		faults.Unlock()
+	}
+}
+
+// currentFaults returns the Faults to apply to a Process being started.
+func currentFaults() *Faults {
+	faults.Lock()
+	defer faults.Unlock()
+	if faults.f == nil {
+		return &Faults{}
+	}
+	return faults.f
+}
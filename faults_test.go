@@ -0,0 +1,37 @@
+package process
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestFaultsStartErr(t *testing.T) {
+	defer InjectFaults(&Faults{StartErr: errors.New("injected")})()
+	o := make(chan *Message)
+	c := collect(o)
+	if p := StartProcess(nil, []string{"true"}, o); p != nil {
+		t.Fatalf("StartProcess = %v, want nil", p)
+	}
+	ms := <-c
+	if len(ms) != 1 || ms[0].Kind != "end" || ms[0].Body != "injected" {
+		t.Errorf("got %+v, want a single end Message with the injected error", ms)
+	}
+}
+
+func TestFaultsDropEvery(t *testing.T) {
+	unixTools(t)
+	defer InjectFaults(&Faults{DropEvery: 2})()
+	o := make(chan *Message)
+	c := collect(o)
+	p := StartProcess(nil, []string{"sh", "-c", "for i in 1 2 3 4; do echo $i; sleep 0.05; done"}, o)
+	<-p.Done
+	var got string
+	for _, m := range <-c {
+		if m.Kind == "stdout" {
+			got += m.Body
+		}
+	}
+	if got != "1\n3\n" {
+		t.Errorf("stdout = %q, want %q", got, "1\n3\n")
+	}
+}
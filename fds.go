@@ -0,0 +1,67 @@
+// Copyright 2012 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package process
+
+import "time"
+
+// FDStats describes the file descriptors a program had open, as sampled
+// every ProcessSpec.FDSampleInterval while it ran.
+type FDStats struct {
+	Peak int // most descriptors open in one sample
+
+	// Leaked counts the descriptors open in the last sample that were
+	// not open, to the same file, in the first: those the program
+	// opened and had not closed by the time it exited.
+	Leaked int
+}
+
+// sampleFDs samples the descriptors process pid has open every interval
+// until the returned function is called, which records the result in p.
+// Samples with no descriptors are ignored, as are those taken after the
+// program has exited but before it has been waited for.
+func (p *Process) sampleFDs(pid int, interval time.Duration) (stop func()) {
+	quit, done := make(chan struct{}), make(chan struct{})
+	var st FDStats
+	var first, last map[string]string
+	sample := func() {
+		fds, err := readFDs(pid)
+		if err != nil || len(fds) == 0 {
+			return
+		}
+		if first == nil {
+			first = fds
+		}
+		last = fds
+		if len(fds) > st.Peak {
+			st.Peak = len(fds)
+		}
+	}
+	go func() {
+		defer close(done)
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		sample()
+		for {
+			select {
+			case <-quit:
+				return
+			case <-t.C:
+				sample()
+			}
+		}
+	}()
+	return func() {
+		close(quit)
+		<-done
+		for fd, file := range last {
+			if first[fd] != file {
+				st.Leaked++
+			}
+		}
+		p.mu.Lock()
+		p.fds = &st
+		p.mu.Unlock()
+	}
+}
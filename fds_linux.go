@@ -0,0 +1,34 @@
+// Copyright 2012 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package process
+
+import (
+	"os"
+	"strconv"
+)
+
+// readFDs returns the descriptors process pid has open, mapped to the
+// files they refer to.
+func readFDs(pid int) (map[string]string, error) {
+	dir := "/proc/" + strconv.Itoa(pid) + "/fd/"
+	d, err := os.Open(dir)
+	if err != nil {
+		return nil, err
+	}
+	names, err := d.Readdirnames(-1)
+	d.Close()
+	if err != nil {
+		return nil, err
+	}
+	fds := make(map[string]string, len(names))
+	for _, name := range names {
+		// A descriptor closed since the directory was read is
+		// left out.
+		if file, err := os.Readlink(dir + name); err == nil {
+			fds[name] = file
+		}
+	}
+	return fds, nil
+}
@@ -0,0 +1,21 @@
+package process
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFDs(t *testing.T) {
+	o := make(chan *Message)
+	c := collect(o)
+	// The shell opens three descriptors and closes one of them.
+	StartProcessSpec(&ProcessSpec{
+		Args:             []string{"sh", "-c", "sleep 0.2; exec 3</dev/null 4</dev/null 5</dev/null; sleep 0.2; exec 4<&-; sleep 0.2"},
+		FDSampleInterval: 10 * time.Millisecond,
+	}, o)
+	ms := <-c
+	m := ms[len(ms)-1]
+	if m.FDs == nil || m.FDs.Peak < 6 || m.FDs.Leaked != 2 {
+		t.Errorf("got %+v, want 2 leaked descriptors", m.FDs)
+	}
+}
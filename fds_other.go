@@ -0,0 +1,13 @@
+// Copyright 2012 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !linux
+
+package process
+
+import "errors"
+
+func readFDs(pid int) (map[string]string, error) {
+	return nil, errors.New("sampling file descriptors is not supported on this system")
+}
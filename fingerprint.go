@@ -0,0 +1,140 @@
+// Copyright 2012 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package process
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// fingerprint returns a digest of the environment the program of spec
+// runs in: its arguments, working directory and environment, the user it
+// runs as, and the spec's locale and limits. For a program run on the
+// server it also covers the program file itself and the server's
+// operating system and architecture, and for one run by a Runner the
+// Runner's settings, such as the image or host, instead. Secrets and
+// credentials are left out, as they may differ on every run.
+func fingerprint(spec *ProcessSpec) string {
+	h := sha256.New()
+	field := func(s string) {
+		io.WriteString(h, strconv.Quote(s)+"\n")
+	}
+	var env []string
+	if spec.Runner != nil {
+		field(runnerIdentity(spec.Runner))
+		// The server's own environment does not reach the program.
+		env = specEnv(spec, nil)
+	} else {
+		field(runtime.GOOS + "/" + runtime.GOARCH)
+		field(fileHash(programFile(spec)))
+		env = environ(spec, nil)
+	}
+	field(spec.Dir)
+	for _, a := range spec.Args {
+		field(a)
+	}
+	sort.Strings(env)
+	for _, kv := range env {
+		field(kv)
+	}
+	if u := spec.User; u != nil {
+		field(fmt.Sprint("user ", u.Uid, u.Gid, u.Groups))
+	}
+	l := spec.Limits
+	field(l.CPU.String())
+	field(strconv.FormatInt(l.AddressSpace, 10))
+	field(strconv.FormatInt(l.FileSize, 10))
+	field(strconv.Itoa(l.OpenFiles))
+	return "sha256:" + hex.EncodeToString(h.Sum(nil))
+}
+
+// runnerIdentity describes where r runs programs. Runners other than
+// the package's own are described by their type alone.
+func runnerIdentity(r Runner) string {
+	switch r := r.(type) {
+	case Docker:
+		s := "docker " + r.Image + " network=" + r.Network
+		for _, m := range r.Mounts {
+			s += " mount=" + m.Source + ":" + m.Target + ":" + strconv.FormatBool(m.ReadOnly)
+		}
+		return s
+	case SSH:
+		return "ssh " + r.Host + " " + strings.Join(r.Options, " ")
+	case Kubernetes:
+		return "kubernetes " + r.Namespace + " " + r.Image
+	}
+	return fmt.Sprintf("%T", r)
+}
+
+// programFile returns the path of the program file spec runs on the
+// server, or "" if it cannot be found.
+func programFile(spec *ProcessSpec) string {
+	path := spec.Args[0]
+	if filepath.Base(path) == path {
+		path, _ = exec.LookPath(path)
+	} else if !filepath.IsAbs(path) {
+		path = filepath.Join(spec.Dir, path)
+	}
+	return path
+}
+
+// fileHashes caches the digests of program files, by path.
+var fileHashes struct {
+	sync.Mutex
+	m map[string]cachedHash
+}
+
+type cachedHash struct {
+	size  int64
+	mtime time.Time
+	sum   string
+}
+
+// fileHash returns a digest of the file at path, or "" if it cannot be
+// read. The digest is computed again only if the file's size or
+// modification time has changed.
+func fileHash(path string) string {
+	if path == "" {
+		return ""
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		return ""
+	}
+	fileHashes.Lock()
+	c, ok := fileHashes.m[path]
+	fileHashes.Unlock()
+	if ok && c.size == fi.Size() && c.mtime.Equal(fi.ModTime()) {
+		return c.sum
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return ""
+	}
+	c = cachedHash{fi.Size(), fi.ModTime(), hex.EncodeToString(h.Sum(nil))}
+	fileHashes.Lock()
+	if fileHashes.m == nil {
+		fileHashes.m = make(map[string]cachedHash)
+	}
+	fileHashes.m[path] = c
+	fileHashes.Unlock()
+	return c.sum
+}
@@ -0,0 +1,67 @@
+package process
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestFingerprint(t *testing.T) {
+	unixTools(t)
+	run := func(spec *ProcessSpec) string {
+		o := make(chan *Message)
+		c := collect(o)
+		StartProcessSpec(spec, o)
+		ms := <-c
+		return ms[len(ms)-1].Fingerprint
+	}
+	a := run(&ProcessSpec{Args: []string{"true"}})
+	if a == "" || a != run(&ProcessSpec{Args: []string{"true"}}) {
+		t.Errorf("fingerprint %q differs between identical runs", a)
+	}
+	if b := run(&ProcessSpec{Args: []string{"true"}, Env: []string{"X=1"}}); b == a {
+		t.Errorf("fingerprint unchanged by the environment")
+	}
+	if b := run(&ProcessSpec{Args: []string{"true"}, Limits: Limits{OpenFiles: 64}}); b == a {
+		t.Errorf("fingerprint unchanged by the limits")
+	}
+}
+
+func TestFingerprintRunner(t *testing.T) {
+	spec := func(r Runner) *ProcessSpec {
+		return &ProcessSpec{Args: []string{"true"}, Runner: r}
+	}
+	a := fingerprint(spec(Docker{Image: "alpine:3.18"}))
+	if a != fingerprint(spec(Docker{Image: "alpine:3.18"})) {
+		t.Errorf("fingerprint differs between identical Docker runs")
+	}
+	for _, r := range []Runner{
+		Docker{Image: "alpine:3.19"},
+		SSH{Host: "a"},
+		Kubernetes{Image: "alpine:3.18"},
+	} {
+		if fingerprint(spec(r)) == a {
+			t.Errorf("fingerprint for %#v is the same as for alpine:3.18 in Docker", r)
+		}
+	}
+	if fingerprint(spec(SSH{Host: "a"})) == fingerprint(spec(SSH{Host: "b"})) {
+		t.Errorf("fingerprint unchanged by the SSH host")
+	}
+}
+
+func TestFileHash(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "prog")
+	if err := ioutil.WriteFile(path, []byte("one"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	a := fileHash(path)
+	if a == "" || a != fileHash(path) {
+		t.Fatalf("fileHash = %q, then %q", a, fileHash(path))
+	}
+	if err := ioutil.WriteFile(path, []byte("two!"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if b := fileHash(path); b == a {
+		t.Errorf("fileHash unchanged after the file changed")
+	}
+}
@@ -0,0 +1,278 @@
+// Copyright 2012 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package process
+
+import (
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A Step is one run of a Group.
+type Step struct {
+	Label string // prefixed to the Label of the step's output Messages
+	Spec  *ProcessSpec
+}
+
+// StepResult is the outcome of one Step.
+type StepResult struct {
+	Label    string
+	ExitCode int    // the program's exit code, or -1 if it did not exit normally
+	Err      string // the step's "end" Message body; "" on success
+	Skipped  bool   // the step was not run because the Group was killed
+	Duration time.Duration
+}
+
+// Failed reports whether the step ran and did not succeed.
+func (r *StepResult) Failed() bool {
+	return !r.Skipped && r.Err != ""
+}
+
+// Result is the outcome of a Group.
+type Result struct {
+	Steps    []StepResult
+	Duration time.Duration
+}
+
+// Summary is sent, encoded as JSON, as the Body of the "summary" Message
+// that precedes the "end" Message of a Group. Durations are in
+// nanoseconds.
+type Summary struct {
+	Succeeded, Failed, Skipped int
+	*Result
+}
+
+// Summary counts the steps of r by outcome.
+func (r *Result) Summary() *Summary {
+	s := &Summary{Result: r}
+	for i := range r.Steps {
+		switch st := &r.Steps[i]; {
+		case st.Skipped:
+			s.Skipped++
+		case st.Failed():
+			s.Failed++
+		default:
+			s.Succeeded++
+		}
+	}
+	return s
+}
+
+// summarize sends a "summary" Message for r to out.
+func summarize(id string, r *Result, out chan<- *Message) {
+	b, err := json.Marshal(r.Summary())
+	if err != nil {
+		panic(err) // Summary always encodes
+	}
+	out <- newMessage(id, "summary", string(b))
+}
+
+// Group runs several Steps, a bounded number at a time, and sends their
+// output as Messages with the Group's id and the step's Label. The end of
+// each step is recorded in the Group's Result. Once all steps have
+// finished, a "summary" Message and a single "end" Message are sent.
+type Group struct {
+	id    string
+	out   chan<- *Message
+	Done  chan struct{} // closed when all steps have finished
+	steps []Step
+
+	mu      sync.Mutex
+	killed  bool
+	running map[*Process]bool
+	result  Result
+}
+
+// StartGroup starts running steps, at most parallel of them at once (all
+// of them if parallel < 1), sending their output on out.
+func StartGroup(steps []Step, parallel int, out chan<- *Message) *Group {
+	if parallel < 1 {
+		parallel = len(steps)
+	}
+	g := &Group{
+		id:      newID(),
+		out:     out,
+		Done:    make(chan struct{}),
+		steps:   steps,
+		running: make(map[*Process]bool),
+		result:  Result{Steps: make([]StepResult, len(steps))},
+	}
+	go g.run(parallel)
+	return g
+}
+
+// Kill stops the running steps, skips those not yet started, and waits for
+// the Group to finish.
+func (g *Group) Kill() {
+	g.mu.Lock()
+	g.killed = true
+	var ps []*Process
+	for p := range g.running {
+		ps = append(ps, p)
+	}
+	g.mu.Unlock()
+	for _, p := range ps {
+		p.Kill()
+	}
+	<-g.Done
+}
+
+// Result returns the outcome of each step. It must not be called before
+// Done is closed.
+func (g *Group) Result() *Result {
+	return &g.result
+}
+
+func (g *Group) run(parallel int) {
+	t0 := time.Now()
+	sem := make(chan bool, parallel)
+	var wg sync.WaitGroup
+	for i := range g.steps {
+		sem <- true
+		wg.Add(1)
+		go func(i int) {
+			g.runStep(i)
+			<-sem
+			wg.Done()
+		}(i)
+	}
+	wg.Wait()
+	g.result.Duration = time.Since(t0)
+
+	summarize(g.id, &g.result, g.out)
+	m := newMessage(g.id, "end", "")
+	if n := g.result.Summary().Failed; n > 0 {
+		m.Body = strconv.Itoa(n) + " of " + strconv.Itoa(len(g.steps)) + " steps failed"
+	}
+	g.out <- m
+	close(g.Done)
+}
+
+// runStep runs step i to completion, relaying its output and recording its
+// result.
+func (g *Group) runStep(i int) {
+	st, r := g.steps[i], &g.result.Steps[i]
+	r.Label, r.ExitCode = st.Label, -1
+	g.mu.Lock()
+	r.Skipped = g.killed
+	g.mu.Unlock()
+	if r.Skipped {
+		return
+	}
+
+	t0 := time.Now()
+	ch := make(chan *Message)
+	started := make(chan *Process, 1)
+	go func() {
+		p := StartProcessSpec(st.Spec, ch)
+		if p != nil {
+			g.mu.Lock()
+			g.running[p] = true
+			kill := g.killed
+			g.mu.Unlock()
+			if kill {
+				go p.Kill()
+			}
+		}
+		started <- p
+	}()
+	for m := range ch {
+		if m.Kind == "end" {
+			r.Err = m.Body
+			m.Release()
+			break
+		}
+		m.Id = g.id
+		if m.Label == "" {
+			m.Label = st.Label
+		} else {
+			m.Label = st.Label + "/" + m.Label
+		}
+		g.out <- m
+	}
+	if p := <-started; p != nil {
+		<-p.Done
+		r.ExitCode = p.status.Code
+		g.mu.Lock()
+		delete(g.running, p)
+		g.mu.Unlock()
+	}
+	r.Duration = time.Since(t0)
+}
+
+// A Matrix maps parameter names to the values each one takes.
+type Matrix map[string][]string
+
+// Steps returns a Step for every combination of the values in m, running
+// spec with each "{name}" in its Args and Env replaced by the parameter's
+// value.
+// Steps are labelled with their parameters, such as
+// "GOARCH=amd64,GOOS=linux", in order of parameter name.
+func (m Matrix) Steps(spec *ProcessSpec) []Step {
+	var names []string
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	steps := []Step{{Spec: spec}}
+	for _, name := range names {
+		var next []Step
+		for _, st := range steps {
+			for _, v := range m[name] {
+				s := *st.Spec
+				s.Args = substitute(st.Spec.Args, "{"+name+"}", v)
+				s.Env = substitute(st.Spec.Env, "{"+name+"}", v)
+				label := name + "=" + v
+				if st.Label != "" {
+					label = st.Label + "," + label
+				}
+				next = append(next, Step{Label: label, Spec: &s})
+			}
+		}
+		steps = next
+	}
+	return steps
+}
+
+// substitute returns a copy of args with old replaced by new.
+func substitute(args []string, old, new string) []string {
+	if args == nil {
+		return nil
+	}
+	a := make([]string, len(args))
+	for i, arg := range args {
+		a[i] = strings.Replace(arg, old, new, -1)
+	}
+	return a
+}
+
+// FileSteps returns a Step for each of files, running spec with "{}" in
+// its Args replaced by the file name, or with the file name appended to
+// Args if none of them contains "{}". Steps are labelled with the file
+// name. Like xargs -P, StartGroup(FileSteps(spec, files), n, out) runs
+// the command over the files n at a time.
+func FileSteps(spec *ProcessSpec, files []string) []Step {
+	placeholder := false
+	for _, arg := range spec.Args {
+		if strings.Contains(arg, "{}") {
+			placeholder = true
+		}
+	}
+	steps := make([]Step, len(files))
+	for i, f := range files {
+		s := *spec
+		if placeholder {
+			s.Args = substitute(spec.Args, "{}", f)
+		} else {
+			s.Args = append(append([]string(nil), spec.Args...), f)
+		}
+		steps[i] = Step{Label: f, Spec: &s}
+	}
+	return steps
+}
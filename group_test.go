@@ -0,0 +1,99 @@
+package process
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMatrixGroup(t *testing.T) {
+	unixTools(t)
+	m := Matrix{"GOOS": {"linux", "darwin"}, "GOARCH": {"amd64", "arm64"}}
+	steps := m.Steps(&ProcessSpec{
+		Args: []string{"sh", "-c", "echo {GOOS}/$GOARCH; test $GOOS = linux"},
+		Env:  []string{"GOOS={GOOS}", "GOARCH={GOARCH}"},
+	})
+	if a, b := steps[0].Spec.Env, steps[1].Spec.Env; a[0] != "GOOS=linux" || b[0] != "GOOS=darwin" || a[1] != "GOARCH=amd64" {
+		t.Errorf("first steps have Env %q and %q, want their own parameters", a, b)
+	}
+	o := make(chan *Message)
+	c := collect(o)
+	g := StartGroup(steps, 2, o)
+	<-g.Done
+
+	var got []string
+	ms := <-c
+	var sum Summary
+	if m := ms[len(ms)-2]; m.Kind != "summary" {
+		t.Errorf("got %+v, want summary", m)
+	} else if err := json.Unmarshal([]byte(m.Body), &sum); err != nil {
+		t.Error(err)
+	} else if sum.Succeeded != 2 || sum.Failed != 2 || sum.Skipped != 0 || len(sum.Steps) != 4 {
+		t.Errorf("summary = %+v", sum)
+	}
+	for _, m := range ms[:len(ms)-2] {
+		if m.Id != g.id || m.Kind != "stdout" {
+			t.Errorf("unexpected Message %+v", m)
+		}
+		got = append(got, m.Label+" "+m.Body)
+	}
+	sort.Strings(got)
+	want := []string{
+		"GOARCH=amd64,GOOS=darwin darwin/amd64\n",
+		"GOARCH=amd64,GOOS=linux linux/amd64\n",
+		"GOARCH=arm64,GOOS=darwin darwin/arm64\n",
+		"GOARCH=arm64,GOOS=linux linux/arm64\n",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Errorf("got %q, want %q", got[i], want[i])
+		}
+	}
+	if end := ms[len(ms)-1]; end.Kind != "end" || end.Body != "2 of 4 steps failed" {
+		t.Errorf("end Message = %+v", end)
+	}
+	for _, r := range g.Result().Steps {
+		if linux := strings.HasSuffix(r.Label, "GOOS=linux"); r.Failed() == linux {
+			t.Errorf("unexpected result %+v", r)
+		}
+	}
+}
+
+func TestGroupKill(t *testing.T) {
+	unixTools(t)
+	spec := &ProcessSpec{Args: []string{"sleep", "10"}}
+	o := make(chan *Message)
+	c := collect(o)
+	g := StartGroup([]Step{{"a", spec}, {"b", spec}}, 1, o)
+	for {
+		g.mu.Lock()
+		n := len(g.running)
+		g.mu.Unlock()
+		if n > 0 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	g.Kill()
+	<-c
+	r := g.Result().Steps
+	if !r[0].Failed() || !r[1].Skipped {
+		t.Errorf("got %+v, want first step killed and second skipped", r)
+	}
+}
+
+func TestFileSteps(t *testing.T) {
+	steps := FileSteps(&ProcessSpec{Args: []string{"wc", "-c"}}, []string{"a", "b"})
+	if len(steps) != 2 || steps[1].Label != "b" || strings.Join(steps[1].Spec.Args, " ") != "wc -c b" {
+		t.Errorf("got %+v", steps)
+	}
+	steps = FileSteps(&ProcessSpec{Args: []string{"cp", "{}", "{}.bak"}}, []string{"a"})
+	if strings.Join(steps[0].Spec.Args, " ") != "cp a a.bak" {
+		t.Errorf("got %+v", steps[0].Spec)
+	}
+}
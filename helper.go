@@ -0,0 +1,33 @@
+// Copyright 2012 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package process
+
+// helperEnv names the environment variable through which the server
+// binary, executed in a program's place by useHelper, is given the helper
+// to carry out.
+const helperEnv = "_PROCESS_HELPER"
+
+// A helper is the set up of a program that must be done in its own
+// process, after os/exec has started it and before the program is
+// executed: the server binary is executed in the program's place to do
+// it, and then executes the program. See useHelper.
+type helper struct {
+	Path string // the program
+	Dir  string // its working directory, inside Root if that is set
+
+	Root    string // the directory to chroot to, from Isolation.Rootfs
+	Proc    bool   // mount a /proc of the program's own under Root
+	Limits  Limits
+	Nice    int
+	User    *User  // set last, as the steps before it need privilege
+	Seccomp []byte // the filter, laid out as the kernel's sock_filter
+
+	Status int // the descriptor on which to report failure
+}
+
+// needed reports whether the program must be started through h.
+func (h *helper) needed() bool {
+	return h.Root != "" || h.Proc || !h.Limits.zero() || h.Nice != 0 || h.Seccomp != nil
+}
@@ -0,0 +1,21 @@
+// Copyright 2012 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !unix
+
+package process
+
+import (
+	"errors"
+	"os/exec"
+)
+
+func (p *Process) useHelper(cmd *exec.Cmd, h *helper) (started func() error, err error) {
+	if !h.Limits.zero() {
+		return nil, errors.New("resource limits are not supported on this system")
+	}
+	// Niceness is all that is left, as isolation and seccomp are refused
+	// before; it is not supported here either, so it is ignored.
+	return func() error { return nil }, nil
+}
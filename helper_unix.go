@@ -0,0 +1,165 @@
+// Copyright 2012 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build unix
+
+package process
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// useHelper arranges for cmd to be started through h, by executing the
+// server binary in its place; see init. The helper takes over cmd's
+// working directory and user. The returned function must be called once
+// cmd has started: it waits for the helper to execute the program, and
+// returns why it could not, as a *StartError if it can tell.
+func (p *Process) useHelper(cmd *exec.Cmd, h *helper) (started func() error, err error) {
+	if cmd.Err != nil {
+		return nil, cmd.Err
+	}
+	self, err := os.Executable()
+	if err != nil {
+		return nil, errors.New("helper: " + err.Error())
+	}
+	// The helper reports its failure on a pipe it closes on exec, as
+	// os/exec does for the programs it executes itself.
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	p.onRelease(func() {
+		r.Close()
+		w.Close()
+	})
+	h.Status = 3 + len(cmd.ExtraFiles)
+	cmd.ExtraFiles = append(cmd.ExtraFiles, w)
+	h.Path, h.Dir = cmd.Path, cmd.Dir
+	if a := cmd.SysProcAttr; a != nil && a.Credential != nil {
+		h.User = &User{Uid: a.Credential.Uid, Gid: a.Credential.Gid, Groups: a.Credential.Groups}
+		a.Credential = nil
+	}
+	b, err := json.Marshal(h)
+	if err != nil {
+		return nil, err
+	}
+	if cmd.Env == nil {
+		cmd.Env = os.Environ()
+	}
+	cmd.Env = append(cmd.Env, helperEnv+"="+string(b))
+	program := &exec.Cmd{Path: cmd.Path, Dir: cmd.Dir}
+	cmd.Path, cmd.Dir = self, ""
+	return func() error {
+		w.Close()
+		b, err := ioutil.ReadAll(r)
+		if err != nil || len(b) == 0 {
+			return err
+		}
+		msg := string(b)
+		if n, err := strconv.Atoi(strings.TrimPrefix(msg, "exec ")); err == nil && strings.HasPrefix(msg, "exec ") {
+			err := &os.PathError{Op: "exec", Path: h.Path, Err: syscall.Errno(n)}
+			return diagnoseIn(h.Root, program, err)
+		}
+		return errors.New(msg)
+	}, nil
+}
+
+// init takes over when the server binary has been started by useHelper:
+// it sets up the program and executes it, never returning. Its failure is
+// reported on the Status descriptor.
+func init() {
+	enc, ok := os.LookupEnv(helperEnv)
+	if !ok {
+		return
+	}
+	var h helper
+	if err := json.Unmarshal([]byte(enc), &h); err != nil {
+		os.Stderr.WriteString("helper: " + err.Error() + "\n")
+		os.Exit(127)
+	}
+	syscall.CloseOnExec(h.Status)
+	err := h.exec()
+	msg := err.Error()
+	if errno, ok := err.(syscall.Errno); ok {
+		msg = "exec " + strconv.Itoa(int(errno))
+	}
+	os.NewFile(uintptr(h.Status), "status").WriteString(msg)
+	os.Exit(127)
+}
+
+// exec sets up the program as h says and executes it.
+func (h *helper) exec() error {
+	var env []string
+	for _, kv := range os.Environ() {
+		if !strings.HasPrefix(kv, helperEnv+"=") {
+			env = append(env, kv)
+		}
+	}
+	// Some of what follows is per-thread, so it must all be done from
+	// the thread that executes the program.
+	runtime.LockOSThread()
+	if h.Proc {
+		root := h.Root
+		if root == "" {
+			root = "/"
+		}
+		if err := mountProc(root); err != nil {
+			return err
+		}
+	}
+	if h.Root != "" {
+		if err := syscall.Chroot(h.Root); err != nil {
+			return errors.New("chroot: " + err.Error())
+		}
+		if h.Dir == "" {
+			// Otherwise the program starts in the server's directory,
+			// outside Root.
+			h.Dir = "/"
+		}
+	}
+	if h.Dir != "" {
+		if err := syscall.Chdir(h.Dir); err != nil {
+			return errors.New("chdir: " + err.Error())
+		}
+	}
+	// Set niceness for the thread, the one Linux changes, which becomes
+	// the program. Without the privilege to lower it, leave it.
+	if h.Nice != 0 {
+		if err := syscall.Setpriority(syscall.PRIO_PROCESS, 0, h.Nice); err != nil && !errors.Is(err, os.ErrPermission) {
+			return errors.New("setting niceness: " + err.Error())
+		}
+	}
+	if u := h.User; u != nil {
+		groups := make([]int, len(u.Groups))
+		for i, g := range u.Groups {
+			groups[i] = int(g)
+		}
+		if err := syscall.Setgroups(groups); err != nil {
+			return errors.New("setgroups: " + err.Error())
+		}
+		if err := syscall.Setgid(int(u.Gid)); err != nil {
+			return errors.New("setgid: " + err.Error())
+		}
+		if err := syscall.Setuid(int(u.Uid)); err != nil {
+			return errors.New("setuid: " + err.Error())
+		}
+	}
+	if err := setLimits(h.Limits); err != nil {
+		return err
+	}
+	if h.Seccomp != nil {
+		if err := installSeccomp(h.Seccomp); err != nil {
+			return errors.New("seccomp: " + err.Error())
+		}
+	}
+	return syscall.Exec(h.Path, os.Args, env)
+}
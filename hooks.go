@@ -0,0 +1,58 @@
+// Copyright 2012 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package process
+
+import (
+	"context"
+	"errors"
+)
+
+// A Hook is a command run before or after a Process' program, in the same
+// directory and with the same settings.
+type Hook struct {
+	Args []string // program name and arguments
+
+	// If ContinueOnError is set, a failure of the hook is ignored.
+	// Otherwise a failing setup hook stops the program from being run and
+	// a failing teardown hook stops the teardown hooks after it; either
+	// way the failure is reported in the "end" Message.
+	ContinueOnError bool
+}
+
+// runHooks runs hooks in order, sending their output labelled with phase,
+// and returns the first error of a hook that may not fail. A hook running
+// when ctx is done or the Process is stopped is killed, and no more are
+// run.
+func (p *Process) runHooks(ctx context.Context, spec *ProcessSpec, hooks []Hook, phase string) error {
+	if len(hooks) == 0 {
+		return nil
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	p.mu.Lock()
+	p.stopHooks = cancel
+	p.mu.Unlock()
+	defer func() {
+		p.mu.Lock()
+		p.stopHooks = nil
+		p.mu.Unlock()
+	}()
+	for _, h := range hooks {
+		if len(h.Args) == 0 {
+			return errors.New(phase + ": No arguments found")
+		}
+		cmd := p.command(ctx, spec, h.Args)
+		cmd.Stdout = p.writer("stdout", phase, nil)
+		cmd.Stderr = p.writer("stderr", phase, nil)
+		err := cmd.Run()
+		if ctx.Err() != nil {
+			return errors.New(phase + ": hook " + h.Args[0] + " was stopped")
+		}
+		if err != nil && !h.ContinueOnError {
+			return errors.New(phase + ": " + err.Error())
+		}
+	}
+	return nil
+}
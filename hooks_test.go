@@ -0,0 +1,126 @@
+package process
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHooks(t *testing.T) {
+	unixTools(t)
+	o := make(chan *Message)
+	c := collect(o)
+	p := StartProcessSpec(&ProcessSpec{
+		Args: []string{"echo", "main"},
+		Setup: []Hook{
+			{Args: []string{"false"}, ContinueOnError: true},
+			{Args: []string{"echo", "setup"}},
+		},
+		Teardown: []Hook{{Args: []string{"echo", "teardown"}}},
+	}, o)
+	<-p.Done
+	var got []string
+	for _, m := range <-c {
+		got = append(got, m.Label+":"+m.Kind+":"+m.Body)
+	}
+	want := []string{"setup:stdout:setup\n", ":stdout:main\n", "teardown:stdout:teardown\n", ":end:"}
+	if len(got) != len(want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Errorf("Message %d = %q, want %q", i, got[i], want[i])
+		}
+	}
+
+	o = make(chan *Message)
+	c = collect(o)
+	p = StartProcessSpec(&ProcessSpec{
+		Args:  []string{"echo", "main"},
+		Setup: []Hook{{Args: []string{"false"}}},
+	}, o)
+	if ms := <-c; p != nil || len(ms) != 1 || ms[0].Body != "setup: exit status 1" {
+		t.Errorf("got %v, %+v; want the setup failure to stop the run", p, ms)
+	}
+}
+
+func TestHookKill(t *testing.T) {
+	unixTools(t)
+	o := make(chan *Message)
+	c := collect(o)
+	p := newProcess(o)
+	go p.launch(context.Background(), &ProcessSpec{
+		Args:  []string{"echo", "main"},
+		Setup: []Hook{{Args: []string{"sleep", "10"}}},
+	})
+	time.Sleep(100 * time.Millisecond)
+	done := make(chan bool)
+	go func() {
+		p.Kill()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Kill did not stop a hanging setup hook")
+	}
+	ms := <-c
+	if m := ms[len(ms)-1]; m.Reason != "killed" || !strings.HasPrefix(m.Body, "setup: ") {
+		t.Errorf("got %+v, want the setup hook killed", m)
+	}
+
+	// A hanging teardown hook is killed too.
+	o = make(chan *Message)
+	c = collect(o)
+	p = StartProcessSpec(&ProcessSpec{
+		Args:     []string{"true"},
+		Teardown: []Hook{{Args: []string{"sleep", "10"}}},
+	}, o)
+	time.Sleep(100 * time.Millisecond)
+	tm := time.AfterFunc(5*time.Second, func() { panic("Kill did not stop a hanging teardown hook") })
+	p.Kill()
+	tm.Stop()
+	ms = <-c
+	if m := ms[len(ms)-1]; !strings.HasPrefix(m.Body, "teardown: ") {
+		t.Errorf("got %+v, want the teardown hook killed", m)
+	}
+}
+
+func TestHookCanceled(t *testing.T) {
+	unixTools(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	o := make(chan *Message)
+	c := collect(o)
+	start := time.Now()
+	p := startSpec(ctx, &ProcessSpec{
+		Args:  []string{"echo", "main"},
+		Setup: []Hook{{Args: []string{"sleep", "10"}}},
+	}, o)
+	ms := <-c
+	if p != nil || time.Since(start) > 5*time.Second {
+		t.Fatalf("setup hook ran on after its context was canceled")
+	}
+	if m := ms[len(ms)-1]; m.Reason != "canceled" {
+		t.Errorf("got %+v, want Reason canceled", m)
+	}
+}
+
+func TestTeardownAfterStartFailure(t *testing.T) {
+	unixTools(t)
+	o := make(chan *Message)
+	c := collect(o)
+	p := StartProcessSpec(&ProcessSpec{
+		Args:     []string{"./does-not-exist"},
+		Setup:    []Hook{{Args: []string{"echo", "setup"}}},
+		Teardown: []Hook{{Args: []string{"echo", "teardown"}}},
+	}, o)
+	var got []string
+	for _, m := range <-c {
+		got = append(got, m.Label+":"+m.Kind)
+	}
+	if p != nil || strings.Join(got, ",") != "setup:stdout,teardown:stdout,:end" {
+		t.Errorf("got %v, %q; want teardown after the failed start", p, got)
+	}
+}
@@ -0,0 +1,111 @@
+// Copyright 2012 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package process
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// InstanceID, if not empty, names this server among several that share
+// clients, such as behind one load balancer. It prefixes the ids of the
+// Processes, Groups and schedules the server creates, separated by a
+// "/", as in "web-1/42", so that a Router can tell which server a
+// Message is for. It must not contain "/", and should be set before any
+// ids are created; newID panics if it does.
+var InstanceID string
+
+// newID returns a new id, unique in this server.
+func newID() string {
+	id := strconv.Itoa(<-uniq)
+	if InstanceID != "" {
+		if err := checkInstance(InstanceID); err != nil {
+			panic("process: InstanceID: " + err.Error())
+		}
+		id = InstanceID + "/" + id
+	}
+	return id
+}
+
+// checkInstance reports an error if instance cannot be an InstanceID.
+func checkInstance(instance string) error {
+	if strings.Contains(instance, "/") {
+		return errors.New("instance " + strconv.Quote(instance) + " contains \"/\"")
+	}
+	return nil
+}
+
+// SplitID splits id into the InstanceID of the server that created it,
+// which is empty if that server had none, and the id local to it.
+func SplitID(id string) (instance, local string) {
+	if i := strings.LastIndexByte(id, '/'); i >= 0 {
+		return id[:i], id[i+1:]
+	}
+	return "", id
+}
+
+// A Router passes Messages from clients, such as "kill" Messages, to the
+// server instance whose InstanceID their Id starts with, wherever the
+// client is connected. It is safe for concurrent use.
+type Router struct {
+	// Resolve, if not nil, is called to find how to deliver Messages for
+	// an instance that is not registered. The function it returns, if
+	// any, is registered for the instance.
+	Resolve func(instance string) (func(*Message) error, error)
+
+	mu        sync.RWMutex
+	instances map[string]func(*Message) error
+}
+
+// Register arranges for Messages for instance to be passed to deliver,
+// which might send them to that server or, for this one, hand them to
+// the right Process. It replaces any earlier registration. Register
+// panics if instance contains "/", as InstanceID must not.
+func (r *Router) Register(instance string, deliver func(*Message) error) {
+	if err := checkInstance(instance); err != nil {
+		panic("process: Register: " + err.Error())
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.instances == nil {
+		r.instances = make(map[string]func(*Message) error)
+	}
+	r.instances[instance] = deliver
+}
+
+// Unregister removes the registration for instance.
+func (r *Router) Unregister(instance string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.instances, instance)
+}
+
+// Route passes m to the instance its Id belongs to, returning the error
+// from delivering it, or an error if that instance is not registered and
+// cannot be resolved, or if the Id could not be one.
+func (r *Router) Route(m *Message) error {
+	instance, _ := SplitID(m.Id)
+	if err := checkInstance(instance); err != nil {
+		return errors.New("bad id " + strconv.Quote(m.Id) + ": " + err.Error())
+	}
+	r.mu.RLock()
+	deliver := r.instances[instance]
+	r.mu.RUnlock()
+	if deliver == nil && r.Resolve != nil {
+		var err error
+		if deliver, err = r.Resolve(instance); err != nil {
+			return err
+		}
+		if deliver != nil {
+			r.Register(instance, deliver)
+		}
+	}
+	if deliver == nil {
+		return errors.New("no route to instance " + strconv.Quote(instance) + " for id " + m.Id)
+	}
+	return deliver(m)
+}
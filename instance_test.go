@@ -0,0 +1,92 @@
+package process
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestInstanceID(t *testing.T) {
+	defer func(s string) { InstanceID = s }(InstanceID)
+	InstanceID = "web-1"
+	id := newID()
+	if !strings.HasPrefix(id, "web-1/") {
+		t.Fatalf("newID() = %q, want prefix web-1/", id)
+	}
+	if instance, local := SplitID(id); instance != "web-1" || local == "" || strings.Contains(local, "/") {
+		t.Errorf("SplitID(%q) = %q, %q", id, instance, local)
+	}
+	if instance, local := SplitID("42"); instance != "" || local != "42" {
+		t.Errorf("SplitID(%q) = %q, %q", "42", instance, local)
+	}
+
+	InstanceID = "a/b"
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("newID accepted an InstanceID containing \"/\"")
+			}
+		}()
+		newID()
+	}()
+}
+
+func TestRouter(t *testing.T) {
+	var r Router
+	var got []string
+	r.Register("a", func(m *Message) error {
+		got = append(got, "a:"+m.Id)
+		return nil
+	})
+	r.Register("", func(m *Message) error {
+		got = append(got, "local:"+m.Id)
+		return nil
+	})
+	for _, id := range []string{"a/1", "2"} {
+		if err := r.Route(&Message{Kind: "kill", Id: id}); err != nil {
+			t.Errorf("Route(%q): %v", id, err)
+		}
+	}
+	if err := r.Route(&Message{Kind: "kill", Id: "b/3"}); err == nil {
+		t.Error("Route to unregistered instance succeeded")
+	}
+	r.Unregister("a")
+	if err := r.Route(&Message{Kind: "kill", Id: "a/4"}); err == nil {
+		t.Error("Route to unregistered instance succeeded")
+	}
+	if err := r.Route(&Message{Kind: "kill", Id: "a/b/5"}); err == nil || !strings.Contains(err.Error(), "bad id") {
+		t.Errorf("Route to an id with two instance parts: %v, want a bad id error", err)
+	}
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("Register accepted an instance containing \"/\"")
+			}
+		}()
+		r.Register("a/b", func(m *Message) error { return nil })
+	}()
+	if s := strings.Join(got, ","); s != "a:a/1,local:2" {
+		t.Errorf("routed %s", s)
+	}
+}
+
+func TestRouterResolve(t *testing.T) {
+	var resolved []string
+	r := Router{Resolve: func(instance string) (func(*Message) error, error) {
+		resolved = append(resolved, instance)
+		if instance != "a" {
+			return nil, nil
+		}
+		return func(*Message) error { return nil }, nil
+	}}
+	for _, id := range []string{"a/1", "a/2"} {
+		if err := r.Route(&Message{Kind: "kill", Id: id}); err != nil {
+			t.Errorf("Route(%q): %v", id, err)
+		}
+	}
+	if err := r.Route(&Message{Kind: "kill", Id: "b/3"}); err == nil {
+		t.Error("Route to unresolved instance succeeded")
+	}
+	if s := strings.Join(resolved, ","); s != "a,b" {
+		t.Errorf("resolved %s, want a once and b", s)
+	}
+}
@@ -0,0 +1,37 @@
+// Copyright 2012 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package process
+
+// Isolation selects the Linux namespaces a program gets of its own, so
+// that it cannot see or affect the server's mounts, processes, network,
+// System V IPC objects or host name. Creating namespaces needs the
+// server to run as root. Isolation is only supported on Linux.
+//
+// PID needs Mount to hide the server's processes: with both, a /proc of
+// the program's own is mounted, while with PID alone the server's /proc
+// still lists them. The server binary is executed to mount it, as for
+// Seccomp.
+type Isolation struct {
+	Mount   bool
+	PID     bool // the program is process 1 of its namespace
+	Network bool // the program sees only a loopback interface, which is down
+	IPC     bool
+	UTS     bool
+
+	// Rootfs, if set, is the root directory of the program, which must be
+	// on a read-only mount. Args[0] is looked up on the server, so it
+	// should be an absolute path inside Rootfs, and Dir is taken to be
+	// inside Rootfs too. With PID and Mount, Rootfs must have a proc
+	// directory, on which the program's /proc is mounted. The server
+	// binary is executed to change to Rootfs, as for Seccomp.
+	//
+	// Rootfs is a chroot, not pivot_root: the server's file systems stay
+	// mounted beneath it, and a program running as root can break out of
+	// it. Rootfs therefore needs a User other than root. Nor does it hide
+	// anything from a program that can reach the server's files another
+	// way, such as through descriptors passed to it, or the server's /proc
+	// without PID and Mount.
+	Rootfs string
+}
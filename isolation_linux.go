@@ -0,0 +1,66 @@
+// Copyright 2012 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package process
+
+import (
+	"errors"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+)
+
+const stReadOnly = 1 // ST_RDONLY, in Statfs_t.Flags
+
+// isolate arranges for cmd to run in the namespaces iso asks for, leaving
+// h to mount /proc and change to its root directory.
+func isolate(cmd *exec.Cmd, iso *Isolation, h *helper) error {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = new(syscall.SysProcAttr)
+	}
+	if iso.Mount {
+		// Unsharing the mount namespace, rather than cloning it, has the
+		// child remount / as MS_PRIVATE|MS_REC before anything else, so
+		// that mounts made in it do not propagate back to the server.
+		cmd.SysProcAttr.Unshareflags |= syscall.CLONE_NEWNS
+	}
+	for _, ns := range []struct {
+		on   bool
+		flag uintptr
+	}{
+		{iso.PID, syscall.CLONE_NEWPID},
+		{iso.Network, syscall.CLONE_NEWNET},
+		{iso.IPC, syscall.CLONE_NEWIPC},
+		{iso.UTS, syscall.CLONE_NEWUTS},
+	} {
+		if ns.on {
+			cmd.SysProcAttr.Cloneflags |= ns.flag
+		}
+	}
+	// A /proc mounted for the server's PID namespace would still list
+	// its processes.
+	h.Proc = iso.PID && iso.Mount
+	if iso.Rootfs == "" {
+		return nil
+	}
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(iso.Rootfs, &st); err != nil {
+		return errors.New("rootfs: " + err.Error())
+	}
+	if st.Flags&stReadOnly == 0 {
+		return errors.New("rootfs " + iso.Rootfs + " is not on a read-only mount")
+	}
+	h.Root = iso.Rootfs
+	return nil
+}
+
+// mountProc mounts a /proc for the calling process's PID namespace on the
+// proc directory of root.
+func mountProc(root string) error {
+	const flags = syscall.MS_NOSUID | syscall.MS_NODEV | syscall.MS_NOEXEC
+	if err := syscall.Mount("proc", filepath.Join(root, "proc"), "proc", flags, ""); err != nil {
+		return errors.New("mounting /proc: " + err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package process
+
+import (
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func TestIsolation(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("creating namespaces needs root")
+	}
+	o := make(chan *Message)
+	c := collect(o)
+	StartProcessSpec(&ProcessSpec{
+		Args:      []string{"sh", "-c", "echo $$; grep -c : /proc/net/dev"},
+		Isolation: &Isolation{PID: true, Network: true, IPC: true, UTS: true},
+	}, o)
+	var out string
+	for _, m := range <-c {
+		out += m.Body
+	}
+	// Each interface in /proc/net/dev has a line with a colon.
+	if out != "1\n1\n" {
+		t.Errorf("output = %q, want pid 1 and only a loopback interface", out)
+	}
+
+	for _, tt := range []struct {
+		user *User
+		want string
+	}{
+		{nil, "User other than root"},
+		{&User{AllowRoot: true}, "User other than root"},
+		{&User{Uid: 65534, Gid: 65534}, "read-only"},
+	} {
+		o = make(chan *Message)
+		c = collect(o)
+		if p := StartProcessSpec(&ProcessSpec{
+			Args:      []string{"/bin/true"},
+			User:      tt.user,
+			Isolation: &Isolation{Mount: true, Rootfs: t.TempDir()},
+		}, o); p != nil {
+			t.Errorf("User %+v: started with a writable rootfs", tt.user)
+			p.Kill()
+		}
+		if ms := <-c; !strings.Contains(ms[0].Body, tt.want) {
+			t.Errorf("User %+v: got %+v, want an error containing %q", tt.user, ms, tt.want)
+		}
+	}
+}
+
+func TestIsolationProc(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("creating namespaces needs root")
+	}
+	count := func(iso *Isolation) string {
+		o := make(chan *Message)
+		c := collect(o)
+		StartProcessSpec(&ProcessSpec{
+			Args:      []string{"sh", "-c", "set -- /proc/[0-9]*; echo $#"},
+			Isolation: iso,
+		}, o)
+		return (<-c)[0].Body
+	}
+	if got := count(&Isolation{PID: true, Mount: true}); got != "1\n" {
+		t.Errorf("/proc lists %q processes, want only the program", got)
+	}
+	if got := count(&Isolation{PID: true}); got == "1\n" {
+		t.Errorf("without Mount, /proc lists only the program; want the server's processes")
+	}
+	if _, err := os.Stat("/proc/1"); err != nil {
+		t.Errorf("the program's /proc replaced the server's: %v", err)
+	}
+}
+
+func TestIsolationMountPrivate(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("creating namespaces needs root")
+	}
+	dir := t.TempDir()
+	o := make(chan *Message)
+	c := collect(o)
+	StartProcessSpec(&ProcessSpec{
+		Args:      []string{"sh", "-c", "mount -t tmpfs none " + dir + " && touch " + dir + "/x && echo mounted"},
+		Isolation: &Isolation{Mount: true},
+	}, o)
+	var out string
+	for _, m := range <-c {
+		out += m.Body
+	}
+	if !strings.HasPrefix(out, "mounted") {
+		t.Skipf("cannot mount a tmpfs here: %q", out)
+	}
+	if _, err := os.Stat(dir + "/x"); err == nil {
+		t.Errorf("a mount made by the program propagated to the server")
+	}
+}
+
+// readOnlyRoot returns a read-only bind mount of the server's root
+// directory, for use as a Rootfs.
+func readOnlyRoot(t *testing.T) string {
+	if os.Geteuid() != 0 {
+		t.Skip("mounting needs root")
+	}
+	dir := t.TempDir()
+	if err := syscall.Mount("/", dir, "", syscall.MS_BIND, ""); err != nil {
+		t.Skip("cannot bind mount /: ", err)
+	}
+	t.Cleanup(func() { syscall.Unmount(dir, syscall.MNT_DETACH) })
+	if err := syscall.Mount("", dir, "", syscall.MS_BIND|syscall.MS_REMOUNT|syscall.MS_RDONLY, ""); err != nil {
+		t.Skip("cannot make a bind mount read-only: ", err)
+	}
+	return dir
+}
+
+func TestRootfsLimits(t *testing.T) {
+	root := readOnlyRoot(t)
+	o := make(chan *Message)
+	c := collect(o)
+	StartProcessSpec(&ProcessSpec{
+		Args:      []string{"/bin/sh", "-c", "pwd; ulimit -n; id -u; set -- /proc/[0-9]*; echo $#"},
+		User:      &User{Uid: 65534, Gid: 65534},
+		Limits:    Limits{OpenFiles: 64},
+		Isolation: &Isolation{Mount: true, PID: true, Rootfs: root},
+	}, o)
+	var out string
+	for _, m := range <-c {
+		out += m.Body
+	}
+	if out != "/\n64\n65534\n1\n" {
+		t.Errorf("output = %q, want the program in Rootfs, limited, run as User and alone in /proc", out)
+	}
+}
@@ -0,0 +1,20 @@
+// Copyright 2012 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !linux
+
+package process
+
+import (
+	"errors"
+	"os/exec"
+)
+
+func isolate(cmd *exec.Cmd, iso *Isolation, h *helper) error {
+	return errors.New("namespace isolation is not supported on this system")
+}
+
+func mountProc(root string) error {
+	return errors.New("mounting /proc is not supported on this system")
+}
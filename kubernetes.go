@@ -0,0 +1,147 @@
+// Copyright 2012 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package process
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// Kubernetes is a Runner that runs each program in a new pod, deleted
+// when the program ends, using the kubectl command-line client. The
+// image needs a POSIX shell.
+//
+// spec.Args is the command run in the pod and spec.Dir, if set, its
+// working directory there. The environment is sent to the pod over its
+// standard input rather than in kubectl's arguments, so secrets are not
+// stored in the pod's definition. spec.User sets the pod's security
+// context. spec.Limits is not supported.
+type Kubernetes struct {
+	Image string // image to run, such as "golang:1.21"
+
+	// Namespace is the namespace to create pods in. If empty, it is
+	// kubectl's current namespace.
+	Namespace string
+
+	// Command is the kubectl client to run. If empty, it is "kubectl".
+	Command string
+}
+
+// Start starts the program of spec in a new pod.
+func (k Kubernetes) Start(spec *ProcessSpec, env []string, stdin io.Reader, stdout, stderr io.Writer) (Run, error) {
+	if k.Image == "" {
+		return nil, errors.New("Kubernetes.Image is empty")
+	}
+	if !spec.Limits.zero() {
+		return nil, errors.New("Limits are not supported by the Kubernetes runner")
+	}
+	vars, err := envInput(env)
+	if err != nil {
+		return nil, err
+	}
+	name, err := runName()
+	if err != nil {
+		return nil, err
+	}
+	args := []string{"run", name, "--image", k.Image, "--restart", "Never",
+		"--rm", "--attach", "--stdin", "--quiet"}
+	if u := spec.User; u != nil {
+		ctx := map[string]interface{}{"runAsUser": u.Uid, "runAsGroup": u.Gid}
+		if len(u.Groups) > 0 {
+			ctx["supplementalGroups"] = u.Groups
+		}
+		b, err := json.Marshal(map[string]interface{}{
+			"apiVersion": "v1",
+			"spec":       map[string]interface{}{"securityContext": ctx},
+		})
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, "--overrides", string(b))
+	}
+	dir := spec.Dir
+	if dir == "" {
+		dir = "."
+	}
+	args = append(args, "--command", "--", "sh", "-c", envScript, "sh", dir)
+	args = append(args, spec.Args...)
+
+	r := &kubernetesRun{k: k, name: name}
+	r.cmd = k.command(args...)
+	r.cmd.Stdout, r.cmd.Stderr = stdout, stderr
+	w, err := r.cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := r.cmd.Start(); err != nil {
+		return nil, err
+	}
+	go writeInput(w, vars, stdin)
+	return r, nil
+}
+
+// command returns an *exec.Cmd running kubectl with args in k.Namespace.
+func (k Kubernetes) command(args ...string) *exec.Cmd {
+	name := k.Command
+	if name == "" {
+		name = "kubectl"
+	}
+	if k.Namespace != "" {
+		args = append([]string{"--namespace", k.Namespace}, args...)
+	}
+	return exec.Command(name, args...)
+}
+
+// kubernetesRun is a program running in a pod.
+type kubernetesRun struct {
+	k    Kubernetes
+	name string
+	cmd  *exec.Cmd
+}
+
+// ID returns the pod's name.
+func (r *kubernetesRun) ID() string {
+	return r.name
+}
+
+// Wait waits for kubectl to exit, which it does with the program's exit
+// status once it has deleted the pod. kubectl's own errors are reported
+// on stderr, with status 1.
+func (r *kubernetesRun) Wait() (ExitStatus, error) {
+	err := r.cmd.Wait()
+	if _, ok := err.(*exec.ExitError); !ok && err != nil {
+		return ExitStatus{}, err
+	}
+	return ExitStatus{Code: r.cmd.ProcessState.ExitCode()}, nil
+}
+
+// Signal sends sig to the program, which runs as the pod's process 1
+// and so ignores signals it has no handler for.
+func (r *kubernetesRun) Signal(sig os.Signal) error {
+	name, ok := nameOfSignal(sig)
+	if !ok {
+		return errors.New("unknown signal: " + sig.String())
+	}
+	return r.kubectl("exec", r.name, "--", "kill", "-s", strings.TrimPrefix(name, "SIG"), "1")
+}
+
+// Kill deletes the pod at once. kubectl is killed first, as the pod
+// would otherwise outlive it if it had not been created yet.
+func (r *kubernetesRun) Kill() error {
+	r.cmd.Process.Kill()
+	return r.kubectl("delete", "pod", r.name, "--grace-period", "0", "--force", "--ignore-not-found", "--wait=false")
+}
+
+func (r *kubernetesRun) kubectl(args ...string) error {
+	out, err := r.k.command(args...).CombinedOutput()
+	if err != nil {
+		return errors.New("kubectl " + args[0] + ": " + strings.TrimSpace(string(out)))
+	}
+	return nil
+}
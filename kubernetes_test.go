@@ -0,0 +1,68 @@
+package process
+
+import (
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeKubectl writes a kubectl client to dir that runs the command of
+// "kubectl run" locally and records the arguments of "kubectl delete".
+func fakeKubectl(t *testing.T, dir string) string {
+	script := `#!/bin/sh
+case "$3" in
+run)
+	while [ "$1" != -- ]; do shift; done
+	shift
+	exec "$@";;
+delete)
+	echo "$@" > ` + dir + `/deleted;;
+esac
+`
+	name := filepath.Join(dir, "kubectl")
+	if err := ioutil.WriteFile(name, []byte(script), 0700); err != nil {
+		t.Fatal(err)
+	}
+	return name
+}
+
+func TestKubernetes(t *testing.T) {
+	unixTools(t)
+	dir := t.TempDir()
+	k := Kubernetes{Image: "alpine", Namespace: "runs", Command: fakeKubectl(t, dir)}
+	o := make(chan *Message)
+	c := collect(o)
+	p := StartProcessSpec(&ProcessSpec{
+		Args:   []string{"sh", "-c", `echo "$A"; cat; exit 3`},
+		Env:    []string{"A=1"},
+		Stdin:  true,
+		Runner: k,
+	}, o)
+	io.WriteString(p, "hello\n")
+	p.CloseStdin()
+	<-p.Done
+	var out string
+	ms := <-c
+	for _, m := range ms {
+		if m.Kind == "stdout" {
+			out += m.Body
+		}
+	}
+	if out != "1\nhello\n" {
+		t.Errorf("stdout = %q, want the environment and the input", out)
+	}
+	if m := ms[len(ms)-1]; m.ExitCode != 3 {
+		t.Errorf("got %+v, want exit status 3", m)
+	}
+
+	o = make(chan *Message)
+	go drain(o)
+	p = StartProcessSpec(&ProcessSpec{Args: []string{"sleep", "60"}, Runner: k}, o)
+	p.Kill()
+	b, _ := ioutil.ReadFile(filepath.Join(dir, "deleted"))
+	if want := "--namespace runs delete pod " + p.run.ID() + " "; !strings.HasPrefix(string(b), want) {
+		t.Errorf("kubectl was run with %q, want %q", b, want)
+	}
+}
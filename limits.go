@@ -0,0 +1,51 @@
+// Copyright 2012 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package process
+
+import "time"
+
+// Limits caps the resources a program may use, as resource limits set
+// before it is executed. Zero fields mean no limit. They are inherited by
+// the processes it starts but apply to each separately. Limits are only
+// supported on Unix. The server binary is executed to set them, as for
+// Seccomp.
+type Limits struct {
+	// CPU is the processor time the program may use. It is sent SIGXCPU
+	// when it is used up, and killed a second later if it has not exited.
+	CPU time.Duration
+
+	// AddressSpace is the size of virtual memory, in bytes, beyond which
+	// allocations fail. How a program fails then is up to it, so such
+	// failures are not recognized in the "end" Message.
+	AddressSpace int64
+
+	// FileSize is the size, in bytes, beyond which the program cannot
+	// write to a file; it is sent SIGXFSZ if it tries.
+	FileSize int64
+
+	// OpenFiles is one more than the largest file descriptor number the
+	// program may open.
+	OpenFiles int
+}
+
+func (l Limits) zero() bool {
+	return l == Limits{}
+}
+
+// limitReason returns the "end" Message Reason for a program run under l
+// that ended as s says, if it was stopped by one of the limits.
+func limitReason(l Limits, s ExitStatus) string {
+	switch s.Signal {
+	case "SIGXCPU":
+		return "cpu-limit"
+	case "SIGXFSZ":
+		return "file-size-limit"
+	case "SIGKILL":
+		if l.CPU > 0 && s.CPU >= l.CPU {
+			return "cpu-limit"
+		}
+	}
+	return ""
+}
@@ -0,0 +1,12 @@
+// Copyright 2012 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build unix && !openbsd
+
+package process
+
+import "syscall"
+
+// rlimitAS is the resource limit on the size of virtual memory.
+const rlimitAS = syscall.RLIMIT_AS
@@ -0,0 +1,11 @@
+// Copyright 2012 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package process
+
+import "syscall"
+
+// rlimitAS is the resource limit on the size of virtual memory. OpenBSD
+// has none, but counts anonymous mappings in the data segment.
+const rlimitAS = syscall.RLIMIT_DATA
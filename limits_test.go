@@ -0,0 +1,46 @@
+//go:build unix
+
+package process
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLimits(t *testing.T) {
+	end := func(spec *ProcessSpec) []*Message {
+		o := make(chan *Message)
+		c := collect(o)
+		StartProcessSpec(spec, o)
+		return <-c
+	}
+	ms := end(&ProcessSpec{
+		Args:   []string{"sh", "-c", "ulimit -n; ulimit -v"},
+		Limits: Limits{AddressSpace: 1 << 30, OpenFiles: 64},
+	})
+	var out string
+	for _, m := range ms {
+		out += m.Body
+	}
+	if out != "64\n1048576\n" {
+		t.Errorf("output = %q, want the limits set", out)
+	}
+
+	ms = end(&ProcessSpec{
+		Args:   []string{"sh", "-c", "while :; do :; done"},
+		Limits: Limits{CPU: time.Second},
+	})
+	if m := ms[len(ms)-1]; m.Reason != "cpu-limit" {
+		t.Errorf("got %+v, want Reason cpu-limit", m)
+	}
+
+	f := filepath.Join(t.TempDir(), "big")
+	ms = end(&ProcessSpec{
+		Args:   []string{"dd", "if=/dev/zero", "of=" + f, "bs=4096", "count=1"},
+		Limits: Limits{FileSize: 1024},
+	})
+	if m := ms[len(ms)-1]; m.Reason != "file-size-limit" || m.Signal != "SIGXFSZ" {
+		t.Errorf("got %+v, want Reason file-size-limit", m)
+	}
+}
@@ -0,0 +1,58 @@
+// Copyright 2012 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build unix
+
+package process
+
+import (
+	"errors"
+	"syscall"
+	"time"
+)
+
+// setLimits sets the resource limits of the calling process to l.
+func setLimits(l Limits) error {
+	set := func(name string, resource int, soft, hard uint64) error {
+		var r syscall.Rlimit
+		rlim(&r.Cur, soft)
+		rlim(&r.Max, hard)
+		if err := syscall.Setrlimit(resource, &r); err != nil {
+			return errors.New("setting the " + name + " limit: " + err.Error())
+		}
+		return nil
+	}
+	if l.CPU > 0 {
+		// A hard limit above the soft one gives the program SIGXCPU
+		// first, without letting it raise the soft limit for good.
+		secs := uint64((l.CPU + time.Second - 1) / time.Second)
+		if err := set("CPU", syscall.RLIMIT_CPU, secs, secs+1); err != nil {
+			return err
+		}
+	}
+	if l.AddressSpace > 0 {
+		n := uint64(l.AddressSpace)
+		if err := set("address space", rlimitAS, n, n); err != nil {
+			return err
+		}
+	}
+	if l.FileSize > 0 {
+		n := uint64(l.FileSize)
+		if err := set("file size", syscall.RLIMIT_FSIZE, n, n); err != nil {
+			return err
+		}
+	}
+	if l.OpenFiles > 0 {
+		n := uint64(l.OpenFiles)
+		if err := set("open files", syscall.RLIMIT_NOFILE, n, n); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rlim sets *f to n, whichever type the system's Rlimit uses.
+func rlim[T int64 | uint64](f *T, n uint64) {
+	*f = T(n)
+}
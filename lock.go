@@ -0,0 +1,69 @@
+// Copyright 2012 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package process
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// ErrLocked is the start error of a Process whose spec sets LockNoWait
+// when its Lock is held by another Process.
+var ErrLocked = errors.New("lock held by another process")
+
+// namedLock is a mutex shared by all Processes whose specs name it.
+type namedLock struct {
+	ch   chan struct{} // holds a value while the lock is held
+	refs int           // holders and waiters
+}
+
+var locks = struct {
+	sync.Mutex
+	m map[string]*namedLock
+}{m: make(map[string]*namedLock)}
+
+// lock acquires the named lock, waiting for it to be released unless
+// noWait is set or ctx is canceled, and returns the function that
+// releases it.
+func lock(ctx context.Context, name string, noWait bool) (unlock func(), err error) {
+	locks.Lock()
+	l := locks.m[name]
+	if l == nil {
+		l = &namedLock{ch: make(chan struct{}, 1)}
+		locks.m[name] = l
+	}
+	l.refs++
+	locks.Unlock()
+
+	if noWait {
+		select {
+		case l.ch <- struct{}{}:
+		default:
+			putLock(name, l)
+			return nil, ErrLocked
+		}
+	} else {
+		select {
+		case l.ch <- struct{}{}:
+		case <-ctx.Done():
+			putLock(name, l)
+			return nil, errCanceled
+		}
+	}
+	return func() {
+		<-l.ch
+		putLock(name, l)
+	}, nil
+}
+
+// putLock drops a reference to l, forgetting it once nobody uses it.
+func putLock(name string, l *namedLock) {
+	locks.Lock()
+	if l.refs--; l.refs == 0 {
+		delete(locks.m, name)
+	}
+	locks.Unlock()
+}
@@ -0,0 +1,36 @@
+package process
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLock(t *testing.T) {
+	unixTools(t)
+	spec := &ProcessSpec{Args: []string{"sleep", "0.2"}, Lock: "db"}
+	o1, o2 := make(chan *Message), make(chan *Message)
+	c1, c2 := collect(o1), collect(o2)
+	p1 := StartProcessSpec(spec, o1)
+
+	o3 := make(chan *Message)
+	c3 := collect(o3)
+	if p := StartProcessSpec(&ProcessSpec{Args: []string{"true"}, Lock: "db", LockNoWait: true}, o3); p != nil {
+		t.Errorf("LockNoWait start succeeded while lock was held")
+	}
+	if ms := <-c3; len(ms) != 1 || ms[0].Body != ErrLocked.Error() {
+		t.Errorf("got %+v, want end Message with ErrLocked", ms)
+	}
+
+	t0 := time.Now()
+	p2 := StartProcessSpec(spec, o2)
+	if d := time.Since(t0); d < 100*time.Millisecond {
+		t.Errorf("second start took %v, want it to wait for the first run", d)
+	}
+	<-p1.Done
+	<-p2.Done
+	<-c1
+	<-c2
+	if len(locks.m) != 0 {
+		t.Errorf("locks not forgotten after release: %v", locks.m)
+	}
+}
@@ -0,0 +1,195 @@
+// Copyright 2012 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package process
+
+import (
+	"context"
+	"strconv"
+	"sync"
+)
+
+// A Manager starts Processes, at most a fixed number at a time, holding
+// later ones in a queue until others end. The queue is ordered by the
+// ProcessSpec's Priority, highest first, and then by arrival. It is safe
+// for concurrent use.
+type Manager struct {
+	max int
+
+	// Preempt, if set, makes a Process that has to queue kill the
+	// running Process of lowest Priority, if that is lower than its own,
+	// to make room. The killed Process' "end" Message reports
+	// "preempted". It should be set before the Manager is used.
+	Preempt bool
+
+	mu      sync.Mutex
+	running int
+	procs   map[*Process]int // running Processes and their priorities
+	victims map[*Process]bool
+	queue   []*waiter
+}
+
+// waiter is a Process waiting in a Manager's queue.
+type waiter struct {
+	priority int
+	ready    chan struct{} // closed when it may start
+	pos      chan int      // its latest position in the queue, from 1
+}
+
+// NewManager returns a Manager that runs at most max Processes at once,
+// or any number if max < 1.
+func NewManager(max int) *Manager {
+	return &Manager{
+		max:     max,
+		procs:   make(map[*Process]int),
+		victims: make(map[*Process]bool),
+	}
+}
+
+// Start is like StartProcessSpec, but waits while the Manager is running
+// as many Processes as it may. While the Process waits, a "queued"
+// Message is sent whenever its place in the queue changes, with its
+// position, counting from 1, as its Body.
+func (m *Manager) Start(spec *ProcessSpec, out chan<- *Message) *Process {
+	return m.StartContext(context.Background(), spec, out)
+}
+
+// StartContext is like Start, but if ctx is canceled while the Process
+// is queued, it is not started and its "end" Message reports "canceled";
+// once it has started, the program is killed as for StartProcessContext.
+func (m *Manager) StartContext(ctx context.Context, spec *ProcessSpec, out chan<- *Message) *Process {
+	p := newProcess(out)
+	if w := m.enqueue(spec.Priority); w != nil {
+		if !m.await(ctx, p, w) {
+			close(p.started)
+			p.end(errCanceled)
+			close(out)
+			return nil
+		}
+	}
+	p = p.launch(ctx, spec)
+	if p == nil {
+		m.done()
+		return nil
+	}
+	m.mu.Lock()
+	m.procs[p] = spec.Priority
+	m.mu.Unlock()
+	go func() {
+		<-p.Done
+		m.mu.Lock()
+		delete(m.procs, p)
+		delete(m.victims, p)
+		m.mu.Unlock()
+		m.done()
+	}()
+	return p
+}
+
+// await waits for w's turn, sending p's position in the queue to p's
+// client, and reports whether it came before ctx was canceled.
+func (m *Manager) await(ctx context.Context, p *Process, w *waiter) bool {
+	for {
+		select {
+		case <-w.ready:
+			return true
+		case n := <-w.pos:
+			p.out <- newMessage(p.id, "queued", strconv.Itoa(n))
+		case <-ctx.Done():
+			if !m.dequeue(w) {
+				// Its turn came anyway; pass it on.
+				m.done()
+			}
+			return false
+		}
+	}
+}
+
+// enqueue takes a place for a Process of the given priority to run,
+// returning nil, or if there is none, adds a waiter for one to the queue,
+// behind those of the same or higher priority.
+func (m *Manager) enqueue(priority int) *waiter {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.max < 1 || m.running < m.max {
+		m.running++
+		return nil
+	}
+	w := &waiter{priority: priority, ready: make(chan struct{}), pos: make(chan int, 1)}
+	i := len(m.queue)
+	for i > 0 && m.queue[i-1].priority < priority {
+		i--
+	}
+	m.queue = append(m.queue, nil)
+	copy(m.queue[i+1:], m.queue[i:])
+	m.queue[i] = w
+	m.renumber(i)
+	if m.Preempt {
+		m.preempt(priority)
+	}
+	return w
+}
+
+// preempt kills the running Process of lowest priority, if that is lower
+// than priority and it is not already being killed. m.mu must be held.
+func (m *Manager) preempt(priority int) {
+	var victim *Process
+	for p, pri := range m.procs {
+		if pri < priority && !m.victims[p] && (victim == nil || pri < m.procs[victim]) {
+			victim = p
+		}
+	}
+	if victim != nil {
+		m.victims[victim] = true
+		go victim.stop("preempted", nil)
+	}
+}
+
+// dequeue removes w from the queue, reporting false if it had already
+// been given a place to run.
+func (m *Manager) dequeue(w *waiter) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, q := range m.queue {
+		if q == w {
+			m.queue = append(m.queue[:i], m.queue[i+1:]...)
+			m.renumber(i)
+			return true
+		}
+	}
+	return false
+}
+
+// done gives up a place to run, passing it to the first waiter if any.
+func (m *Manager) done() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.queue) == 0 {
+		m.running--
+		return
+	}
+	close(m.queue[0].ready)
+	m.queue = m.queue[1:]
+	m.renumber(0)
+}
+
+// renumber tells the waiters from index i on of their new positions.
+// m.mu must be held.
+func (m *Manager) renumber(i int) {
+	for ; i < len(m.queue); i++ {
+		w := m.queue[i]
+		select {
+		case <-w.pos: // replace a position not yet reported
+		default:
+		}
+		w.pos <- i + 1
+	}
+}
+
+// Queued returns the number of Processes waiting to start.
+func (m *Manager) Queued() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.queue)
+}
@@ -0,0 +1,92 @@
+package process
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestManager(t *testing.T) {
+	unixTools(t)
+	m := NewManager(1)
+	o := make(chan *Message)
+	go drain(o)
+	a := m.Start(&ProcessSpec{Args: []string{"cat"}, Stdin: true}, o)
+
+	ob := make(chan *Message)
+	cb := collect(ob)
+	go m.Start(&ProcessSpec{Args: []string{"echo", "b"}}, ob)
+	for m.Queued() != 1 {
+		time.Sleep(time.Millisecond)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	oc := make(chan *Message)
+	cc := collect(oc)
+	go m.StartContext(ctx, &ProcessSpec{Args: []string{"echo", "c"}}, oc)
+	for m.Queued() != 2 {
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	ms := <-cc
+	if len(ms) != 2 || ms[0].Kind != "queued" || ms[0].Body != "2" || ms[1].Reason != "canceled" {
+		t.Errorf("got %+v, want c queued second and then canceled", ms)
+	}
+
+	a.CloseStdin()
+	ms = <-cb
+	if len(ms) != 3 || ms[0].Kind != "queued" || ms[0].Body != "1" || ms[1].Body != "b\n" {
+		t.Errorf("got %+v, want b queued first and then run", ms)
+	}
+	if n := m.Queued(); n != 0 {
+		t.Errorf("Queued() = %d, want 0", n)
+	}
+}
+
+func TestManagerPriority(t *testing.T) {
+	unixTools(t)
+	m := NewManager(1)
+	ot := make(chan *Message)
+	go drain(ot)
+	p := m.Start(&ProcessSpec{Args: []string{"cat"}, Stdin: true}, ot)
+	ol, oh := make(chan *Message), make(chan *Message)
+	cl, ch := collect(ol), collect(oh)
+	go m.Start(&ProcessSpec{Args: []string{"echo", "low"}}, ol)
+	for m.Queued() != 1 {
+		time.Sleep(time.Millisecond)
+	}
+	go m.Start(&ProcessSpec{Args: []string{"echo", "high"}, Priority: 1}, oh)
+	for m.Queued() != 2 {
+		time.Sleep(time.Millisecond)
+	}
+	p.CloseStdin()
+	if ms := <-ch; len(ms) != 3 || ms[0].Body != "1" || ms[1].Body != "high\n" {
+		t.Errorf("got %+v, want high queued first", ms)
+	}
+	// low may be told it is first again before it starts.
+	var got []string
+	for _, m := range <-cl {
+		got = append(got, m.Body)
+	}
+	if g := strings.Join(got, ","); !strings.HasPrefix(g, "1,2,") || !strings.HasSuffix(g, ",low\n,") {
+		t.Errorf("got %q, want low queued first and then second", got)
+	}
+}
+
+func TestManagerPreempt(t *testing.T) {
+	unixTools(t)
+	m := NewManager(1)
+	m.Preempt = true
+	oa := make(chan *Message)
+	ca := collect(oa)
+	m.Start(&ProcessSpec{Args: []string{"sleep", "60"}}, oa)
+	ob := make(chan *Message)
+	cb := collect(ob)
+	go m.Start(&ProcessSpec{Args: []string{"echo", "b"}, Priority: 1}, ob)
+	if ms := <-ca; ms[len(ms)-1].Reason != "preempted" {
+		t.Errorf("got %+v, want a preempted", ms[len(ms)-1])
+	}
+	if ms := <-cb; len(ms) != 3 || ms[0].Kind != "queued" || ms[1].Body != "b\n" {
+		t.Errorf("got %+v, want b queued and then run", ms)
+	}
+}
@@ -0,0 +1,28 @@
+// Copyright 2012 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package process
+
+// PTYNice and BatchNice are the niceness programs run at, so that
+// interactive programs in PTY mode stay responsive while batch programs
+// keep the processor busy. Zero, the default, leaves a program at the
+// server's own niceness; otherwise the server binary is executed to set
+// it before executing the program, as for Seccomp. A server may change
+// them before starting any Processes.
+//
+// Lowering niceness, such as setting PTYNice below zero, needs privilege;
+// without it, programs run at the server's niceness instead. Niceness is
+// not supported on all systems, nor with a Runner.
+var (
+	PTYNice   = 0
+	BatchNice = 0
+)
+
+// niceness returns the niceness to run spec's program at, or 0.
+func niceness(spec *ProcessSpec) int {
+	if spec.PTY {
+		return PTYNice
+	}
+	return BatchNice
+}
@@ -0,0 +1,34 @@
+package process
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestNice(t *testing.T) {
+	script := `set -- $(cat /proc/$$/stat); echo ${19}`
+	nice := func(spec *ProcessSpec) string {
+		o := make(chan *Message)
+		c := collect(o)
+		spec.Args = []string{"sh", "-c", script}
+		StartProcessSpec(spec, o)
+		ms := <-c
+		return ms[0].Body
+	}
+	if got := nice(&ProcessSpec{}); got != "0\n" {
+		t.Errorf("by default, batch program runs at niceness %q, want 0", got)
+	}
+	defer func(b, p int) { BatchNice, PTYNice = b, p }(BatchNice, PTYNice)
+	BatchNice, PTYNice = 3, -2
+	if got := nice(&ProcessSpec{}); got != "3\n" {
+		t.Errorf("batch program runs at niceness %q, want 3", got)
+	}
+	if os.Geteuid() != 0 {
+		t.Skip("lowering niceness needs root")
+	}
+	if got, want := nice(&ProcessSpec{PTY: true}), strconv.Itoa(PTYNice); !strings.HasPrefix(got, want) {
+		t.Errorf("PTY program runs at niceness %q, want %s", got, want)
+	}
+}
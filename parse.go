@@ -0,0 +1,103 @@
+// Copyright 2012 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package process
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"strconv"
+)
+
+// Limits on inbound Messages accepted by ParseMessage.
+const (
+	maxMessageLen = 1 << 20 // encoded size of a whole Message
+	maxIdLen      = 128
+)
+
+// inKinds is the set of Message kinds a client may send.
+var inKinds = map[string]bool{
+	"run":       true,
+	"kill":      true,
+	"pause":     true,
+	"resume":    true,
+	"signal":    true,
+	"stdin":     true,
+	"stdin-eof": true,
+	"resize":    true,
+}
+
+// ProtocolError is returned by ParseMessage for malformed client input.
+// Handlers should report it back to the client as an error frame rather
+// than treat it as a server failure.
+type ProtocolError struct {
+	Field  string // offending Message field, or "" for the whole Message
+	Reason string
+}
+
+func (e *ProtocolError) Error() string {
+	if e.Field == "" {
+		return "process: bad message: " + e.Reason
+	}
+	return "process: bad message " + e.Field + ": " + e.Reason
+}
+
+// ParseMessage decodes a single JSON-encoded Message received from a client
+// and checks that it is well formed: the encoding is within size limits,
+// has no unknown fields or trailing data, carries a non-empty Id, names
+// one of the inbound kinds and sets none of the fields describing output
+// or how a Process ended. Any failure is reported as a *ProtocolError.
+func ParseMessage(b []byte) (*Message, error) {
+	if len(b) > maxMessageLen {
+		return nil, &ProtocolError{Reason: "too large"}
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	m := new(Message)
+	if err := dec.Decode(m); err != nil {
+		return nil, &ProtocolError{Reason: err.Error()}
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, &ProtocolError{Reason: "trailing data"}
+	}
+	switch {
+	case m.Id == "":
+		return nil, &ProtocolError{"Id", "missing"}
+	case len(m.Id) > maxIdLen:
+		return nil, &ProtocolError{"Id", "too long"}
+	case !inKinds[m.Kind]:
+		return nil, &ProtocolError{"Kind", "unknown kind " + strconv.Quote(m.Kind)}
+	}
+	if f := outboundField(m); f != "" {
+		return nil, &ProtocolError{f, "only sent by the server"}
+	}
+	return m, nil
+}
+
+// outboundField returns the name of a field of m that is set but only
+// has meaning in Messages the server sends, or "" if there is none.
+func outboundField(m *Message) string {
+	for _, f := range []struct {
+		name string
+		set  bool
+	}{
+		{"Label", m.Label != ""},
+		{"Progress", m.Progress != nil},
+		{"ExitCode", m.ExitCode != 0},
+		{"Signal", m.Signal != ""},
+		{"Reason", m.Reason != ""},
+		{"Termination", m.Termination != ""},
+		{"Stats", m.Stats != nil},
+		{"Leaked", m.Leaked != 0},
+		{"FDs", m.FDs != nil},
+		{"Fingerprint", m.Fingerprint != ""},
+		{"ExitCodes", m.ExitCodes != nil},
+	} {
+		if f.set {
+			return f.name
+		}
+	}
+	return ""
+}
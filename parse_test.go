@@ -0,0 +1,61 @@
+package process
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseMessage(t *testing.T) {
+	for _, tt := range []struct {
+		in    string
+		field string // "" means the message is valid
+	}{
+		{`{"Id":"1","Kind":"run","Body":"ls"}`, ""},
+		{`{"Id":"1","Kind":"kill"}`, ""},
+		{`{"Id":"","Kind":"kill"}`, "Id"},
+		{`{"Id":"` + strings.Repeat("x", maxIdLen+1) + `","Kind":"kill"}`, "Id"},
+		{`{"Id":"1","Kind":"stdout"}`, "Kind"},
+		{`{"Id":"1","Kind":"kill","Extra":1}`, "-"},
+		{`{"Id":"1","Kind":"kill"} {}`, "-"},
+		{`{"Id":1,"Kind":"kill"}`, "-"},
+		{`[]`, "-"},
+		{`{"Id":"1","Kind":"kill","Reason":"oom-killed"}`, "Reason"},
+		{`{"Id":"1","Kind":"kill","ExitCode":3}`, "ExitCode"},
+		{`{"Id":"1","Kind":"kill","Stats":{}}`, "Stats"},
+		{`{"Id":"1","Kind":"kill","ExitCodes":[0]}`, "ExitCodes"},
+	} {
+		m, err := ParseMessage([]byte(tt.in))
+		if tt.field == "" {
+			if err != nil {
+				t.Errorf("ParseMessage(%s): %v", tt.in, err)
+			}
+			continue
+		}
+		perr, ok := err.(*ProtocolError)
+		if !ok {
+			t.Errorf("ParseMessage(%s) = %v, %v; want *ProtocolError", tt.in, m, err)
+			continue
+		}
+		if tt.field != "-" && perr.Field != tt.field {
+			t.Errorf("ParseMessage(%s): error on %q, want %q", tt.in, perr.Field, tt.field)
+		}
+	}
+}
+
+func FuzzParseMessage(f *testing.F) {
+	f.Add([]byte(`{"Id":"1","Kind":"run","Body":"ls"}`))
+	f.Add([]byte(`{"Id":"1","Kind":"kill"}`))
+	f.Add([]byte(`{"Id":"\u0000","Kind":"kill","Body":null}`))
+	f.Fuzz(func(t *testing.T, b []byte) {
+		m, err := ParseMessage(b)
+		if err != nil {
+			if _, ok := err.(*ProtocolError); !ok {
+				t.Fatalf("ParseMessage returned %T, want *ProtocolError", err)
+			}
+			return
+		}
+		if m.Id == "" || len(m.Id) > maxIdLen || !inKinds[m.Kind] || outboundField(m) != "" {
+			t.Fatalf("ParseMessage accepted invalid message %+v", m)
+		}
+	})
+}
@@ -0,0 +1,165 @@
+// Copyright 2012 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package process
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// A PeerRegistry lists the server instances that share clients, so that
+// a Message for a Process on one of them can be sent there. An
+// implementation might watch a key prefix in etcd or a service in
+// Consul.
+type PeerRegistry interface {
+	// Peers returns the URL of each instance's Peers handler, by
+	// InstanceID.
+	Peers() (map[string]string, error)
+}
+
+// StaticPeers is a fixed PeerRegistry, such as one read from a
+// configuration file.
+type StaticPeers map[string]string
+
+func (s StaticPeers) Peers() (map[string]string, error) {
+	return s, nil
+}
+
+// Peers passes Messages from clients, such as "kill", "stdin" and
+// "resize" Messages, to the instance whose Processes they are for: those
+// whose Id starts with this server's InstanceID are handled by Local, and
+// others are posted to the instance's Peers handler, found in Registry.
+// Peers is itself that handler, accepting the Messages peers post and
+// passing them to Local. Output stays with the instance running the
+// Process.
+//
+// Peers authenticate to each other with Secret, sent as a bearer token.
+// If Secret is empty, posts are only accepted over TLS from clients with
+// a verified certificate, and Client must present one.
+type Peers struct {
+	Registry PeerRegistry
+	Local    func(*Message) error // handles Messages for this instance
+	Secret   string
+	Client   *http.Client // nil means http.DefaultClient
+
+	once   sync.Once
+	router Router
+}
+
+// Route passes m to Local or posts it to the peer it is for, returning
+// the error from handling it. A peer's URL is looked up in Registry the
+// first time a Message is routed to it, and again after a post to it
+// fails.
+func (p *Peers) Route(m *Message) error {
+	p.once.Do(func() { p.router.Resolve = p.resolve })
+	return p.router.Route(m)
+}
+
+// resolve returns the function delivering Messages for instance.
+func (p *Peers) resolve(instance string) (func(*Message) error, error) {
+	if instance == InstanceID {
+		return p.Local, nil
+	}
+	peers, err := p.Registry.Peers()
+	if err != nil {
+		return nil, err
+	}
+	url, ok := peers[instance]
+	if !ok {
+		return nil, nil
+	}
+	return func(m *Message) error {
+		err := p.post(url, instance, m)
+		if _, ok := err.(peerError); !ok && err != nil {
+			// The peer may have moved; look it up again next time.
+			p.router.Unregister(instance)
+		}
+		return err
+	}, nil
+}
+
+// peerError is an error reported by a peer in handling a Message.
+type peerError string
+
+func (e peerError) Error() string { return string(e) }
+
+// post posts m to the Peers handler of instance at url.
+func (p *Peers) post(url, instance string, m *Message) error {
+	b, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", url, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if p.Secret != "" {
+		req.Header.Set("Authorization", "Bearer "+p.Secret)
+	}
+	c := p.Client
+	if c == nil {
+		c = http.DefaultClient
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := ioutil.ReadAll(resp.Body)
+		return peerError("peer " + instance + ": " + strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// authorized reports whether r comes from a peer.
+func (p *Peers) authorized(r *http.Request) bool {
+	if p.Secret == "" {
+		return r.TLS != nil && len(r.TLS.VerifiedChains) > 0
+	}
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return subtle.ConstantTimeCompare([]byte(token), []byte(p.Secret)) == 1
+}
+
+// ServeHTTP handles a Message posted by a peer's Route with Local. The
+// Message must pass ParseMessage.
+func (p *Peers) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !p.authorized(r) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	b, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxMessageLen))
+	if err != nil {
+		http.Error(w, "reading message: "+err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+	m, err := ParseMessage(b)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if instance, _ := SplitID(m.Id); instance != InstanceID {
+		http.Error(w, "id "+m.Id+" is not for instance "+strconv.Quote(InstanceID), http.StatusMisdirectedRequest)
+		return
+	}
+	if err := p.Local(m); err != nil {
+		code := http.StatusInternalServerError
+		if _, ok := err.(*ProtocolError); ok {
+			code = http.StatusBadRequest
+		}
+		http.Error(w, err.Error(), code)
+	}
+}
@@ -0,0 +1,112 @@
+package process
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPeersRoute(t *testing.T) {
+	defer func(s string) { InstanceID = s }(InstanceID)
+	InstanceID = "a"
+
+	var remote []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer s3cret" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		var m Message
+		json.NewDecoder(r.Body).Decode(&m)
+		if m.Kind == "resize" {
+			http.Error(w, "bad size", http.StatusBadRequest)
+			return
+		}
+		remote = append(remote, m.Kind+":"+m.Id)
+	}))
+	defer srv.Close()
+
+	var local []string
+	p := &Peers{
+		Registry: StaticPeers{"b": srv.URL},
+		Local: func(m *Message) error {
+			local = append(local, m.Kind+":"+m.Id)
+			return nil
+		},
+		Secret: "s3cret",
+	}
+	for _, id := range []string{"a/1", "b/2", "b/3"} {
+		if err := p.Route(&Message{Kind: "kill", Id: id}); err != nil {
+			t.Errorf("Route(%q): %v", id, err)
+		}
+	}
+	if s := strings.Join(local, ","); s != "kill:a/1" {
+		t.Errorf("handled locally: %s", s)
+	}
+	if s := strings.Join(remote, ","); s != "kill:b/2,kill:b/3" {
+		t.Errorf("handled remotely: %s", s)
+	}
+	if err := p.Route(&Message{Kind: "resize", Id: "b/3"}); err == nil || !strings.Contains(err.Error(), "bad size") {
+		t.Errorf("Route of rejected Message: %v", err)
+	}
+	if err := p.Route(&Message{Kind: "kill", Id: "c/4"}); err == nil {
+		t.Error("Route to unknown instance succeeded")
+	}
+	p.Secret = "wrong"
+	if err := p.Route(&Message{Kind: "kill", Id: "b/5"}); err == nil {
+		t.Error("Route with the wrong secret succeeded")
+	}
+}
+
+func TestPeersServeHTTP(t *testing.T) {
+	defer func(s string) { InstanceID = s }(InstanceID)
+	InstanceID = "a"
+
+	var got []string
+	p := &Peers{
+		Local: func(m *Message) error {
+			if m.Kind == "signal" {
+				return &ProtocolError{"Body", "unknown signal"}
+			}
+			got = append(got, m.Kind+":"+m.Id)
+			return nil
+		},
+		Secret: "s3cret",
+	}
+	for _, tt := range []struct {
+		auth, body string
+		code       int
+	}{
+		{"Bearer s3cret", `{"Kind":"kill","Id":"a/1"}`, http.StatusOK},
+		{"", `{"Kind":"kill","Id":"a/2"}`, http.StatusUnauthorized},
+		{"Bearer guess", `{"Kind":"kill","Id":"a/3"}`, http.StatusUnauthorized},
+		{"Bearer s3cret", `{"Kind":"kill","Id":"b/1"}`, http.StatusMisdirectedRequest},
+		{"Bearer s3cret", `{"Kind":"signal","Id":"a/1","Body":"SIGFOO"}`, http.StatusBadRequest},
+		{"Bearer s3cret", `{"Kind":"end","Id":"a/1"}`, http.StatusBadRequest},
+		{"Bearer s3cret", `{`, http.StatusBadRequest},
+		{"Bearer s3cret", `{"Kind":"stdin","Id":"a/1","Body":"` + strings.Repeat("x", maxMessageLen) + `"}`, http.StatusRequestEntityTooLarge},
+	} {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("POST", "/", strings.NewReader(tt.body))
+		if tt.auth != "" {
+			r.Header.Set("Authorization", tt.auth)
+		}
+		p.ServeHTTP(w, r)
+		if w.Code != tt.code {
+			t.Errorf("posting %.40s: status %d, want %d", tt.body, w.Code, tt.code)
+		}
+	}
+	if s := strings.Join(got, ","); s != "kill:a/1" {
+		t.Errorf("handled %s", s)
+	}
+
+	// Without a Secret, only TLS clients with certificates are accepted.
+	p.Secret = ""
+	w := httptest.NewRecorder()
+	p.ServeHTTP(w, httptest.NewRequest("POST", "/", strings.NewReader(`{"Kind":"kill","Id":"a/1"}`)))
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("post without a Secret or certificate: status %d", w.Code)
+	}
+}
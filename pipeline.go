@@ -0,0 +1,167 @@
+// Copyright 2012 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package process
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// A Pipeline runs several programs connected as by the shell's "|", each
+// stage's standard output feeding the next one's standard input. Its
+// Messages carry the Pipeline's id, with the stage's Label as their Label,
+// or as a prefix to it as for a Group; an empty Label stands for the
+// stage's number, counting from 1. Each stage's stderr is sent, but only
+// the last stage's stdout. Once every stage has exited, a single "end"
+// Message is sent, with ExitCode, Signal and Reason as the last stage's,
+// ExitCodes holding every stage's exit code, and a Body listing the
+// stages that failed, if any, with their errors, such as "1: signal:
+// SIGPIPE", which name the signal that terminated a stage.
+type Pipeline struct {
+	id     string
+	out    chan<- *Message
+	Done   chan struct{} // closed once the "end" Message has been sent
+	stages []Step
+
+	mu     sync.Mutex
+	killed bool
+	procs  []*Process // the stages that have started
+}
+
+// StartPipeline starts stages, sending their output on out. Stages may
+// not use Stdin, PTY or a Runner. The first stage reads from the null
+// device.
+func StartPipeline(stages []Step, out chan<- *Message) (*Pipeline, error) {
+	if len(stages) == 0 {
+		return nil, errors.New("pipeline has no stages")
+	}
+	for i := range stages {
+		if s := stages[i].Spec; s.Stdin || s.PTY || s.Runner != nil {
+			return nil, errors.New("pipeline stage " + stages[i].label(i) + " uses Stdin, PTY or a Runner")
+		}
+	}
+	// Connect the stages before starting any, so that each pipe is
+	// closed in the server as soon as the stages at its ends have it.
+	ins := make([]*os.File, len(stages))
+	outs := make([]*os.File, len(stages))
+	for i := 0; i < len(stages)-1; i++ {
+		r, w, err := os.Pipe()
+		if err != nil {
+			for _, f := range append(ins, outs...) {
+				if f != nil {
+					f.Close()
+				}
+			}
+			return nil, err
+		}
+		outs[i], ins[i+1] = w, r
+	}
+	pl := &Pipeline{
+		id:     newID(),
+		out:    out,
+		Done:   make(chan struct{}),
+		stages: stages,
+	}
+	go pl.run(ins, outs)
+	return pl, nil
+}
+
+// label returns the Label of st, the stage at index i of a Pipeline.
+func (st *Step) label(i int) string {
+	if st.Label == "" {
+		return strconv.Itoa(i + 1)
+	}
+	return st.Label
+}
+
+// Kill stops every stage and waits for the Pipeline to finish.
+func (pl *Pipeline) Kill() {
+	pl.mu.Lock()
+	pl.killed = true
+	ps := append([]*Process(nil), pl.procs...)
+	pl.mu.Unlock()
+	for _, p := range ps {
+		p.Kill()
+	}
+	<-pl.Done
+}
+
+func (pl *Pipeline) run(ins, outs []*os.File) {
+	ends := make([]*Message, len(pl.stages))
+	var wg sync.WaitGroup
+	for i := range pl.stages {
+		wg.Add(1)
+		go func(i int) {
+			ends[i] = pl.runStage(i, ins[i], outs[i])
+			wg.Done()
+		}(i)
+	}
+	wg.Wait()
+
+	last := ends[len(ends)-1]
+	m := newMessage(pl.id, "end", "")
+	m.ExitCode, m.Signal, m.Reason = last.ExitCode, last.Signal, last.Reason
+	var failed []string
+	for i, e := range ends {
+		code := e.ExitCode
+		if e.Stats == nil {
+			// Only a stage that ran has Stats.
+			code = NotStarted
+		}
+		m.ExitCodes = append(m.ExitCodes, code)
+		if e.Body != "" {
+			failed = append(failed, pl.stages[i].label(i)+": "+e.Body)
+		}
+		e.Release()
+	}
+	m.Body = strings.Join(failed, "; ")
+	pl.out <- m
+	close(pl.Done)
+}
+
+// runStage runs stage i with the given ends of its pipes, relaying its
+// output, and returns its "end" Message.
+func (pl *Pipeline) runStage(i int, in, out *os.File) *Message {
+	label := pl.stages[i].label(i)
+	ch := make(chan *Message)
+	p := newProcess(ch)
+	p.pipeIn, p.pipeOut = in, out
+	go func() {
+		started := p.launch(context.Background(), pl.stages[i].Spec)
+		// The program has its own copies of the pipe now, or has failed
+		// to start; either way the stages next to it must see it close.
+		for _, f := range []*os.File{in, out} {
+			if f != nil {
+				f.Close()
+			}
+		}
+		if started != nil {
+			pl.mu.Lock()
+			pl.procs = append(pl.procs, p)
+			kill := pl.killed
+			pl.mu.Unlock()
+			if kill {
+				go p.Kill()
+			}
+		}
+	}()
+	for m := range ch {
+		if m.Kind == "end" {
+			return m
+		}
+		m.Id = pl.id
+		if m.Label == "" {
+			m.Label = label
+		} else {
+			m.Label = label + "/" + m.Label
+		}
+		pl.out <- m
+	}
+	panic("unreachable") // every Process ends with an "end" Message
+}
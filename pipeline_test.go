@@ -0,0 +1,118 @@
+package process
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPipeline(t *testing.T) {
+	unixTools(t)
+	out := make(chan *Message)
+	ms := collect(out)
+	pl, err := StartPipeline([]Step{
+		{Label: "gen", Spec: &ProcessSpec{Args: []string{"sh", "-c", "printf 'b\\na\\nc\\n'; echo oops >&2"}}},
+		{Spec: &ProcessSpec{Args: []string{"sort"}}},
+		{Label: "count", Spec: &ProcessSpec{Args: []string{"sh", "-c", "cat; exit 2"}}},
+	}, out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stdout, stderr string
+	var end *Message
+	for _, m := range <-ms {
+		if m.Id != pl.id {
+			t.Errorf("Message %s has Id %q, want %q", m.Kind, m.Id, pl.id)
+		}
+		switch m.Kind {
+		case "stdout":
+			if m.Label != "count" {
+				t.Errorf("stdout from stage %q", m.Label)
+			}
+			stdout += m.Body
+		case "stderr":
+			stderr += m.Label + ":" + m.Body
+		case "end":
+			end = m
+		}
+	}
+	<-pl.Done
+	if stdout != "a\nb\nc\n" {
+		t.Errorf("stdout = %q", stdout)
+	}
+	if stderr != "gen:oops\n" {
+		t.Errorf("stderr = %q", stderr)
+	}
+	if end.ExitCode != 2 || !reflect.DeepEqual(end.ExitCodes, []int{0, 0, 2}) {
+		t.Errorf("end ExitCode %d, ExitCodes %v", end.ExitCode, end.ExitCodes)
+	}
+	if end.Body != "count: exit status 2" {
+		t.Errorf("end Body = %q", end.Body)
+	}
+}
+
+func TestPipelineEarlyExit(t *testing.T) {
+	unixTools(t)
+	// The first stage must see its reader go away rather than block.
+	out := make(chan *Message)
+	ms := collect(out)
+	pl, err := StartPipeline([]Step{
+		{Spec: &ProcessSpec{Args: []string{"yes"}}},
+		{Spec: &ProcessSpec{Args: []string{"head", "-n", "2"}}},
+	}, out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.AfterFunc(5*time.Second, pl.Kill)
+	got := <-ms
+	end := got[len(got)-1]
+	if end.ExitCode != 0 || !reflect.DeepEqual(end.ExitCodes, []int{-1, 0}) || end.Body != "1: signal: SIGPIPE" {
+		t.Errorf("end %+v, want the first stage signaled", end)
+	}
+}
+
+func TestPipelineNotStarted(t *testing.T) {
+	unixTools(t)
+	out := make(chan *Message)
+	ms := collect(out)
+	if _, err := StartPipeline([]Step{
+		{Spec: &ProcessSpec{Args: []string{"./does-not-exist"}}},
+		{Spec: &ProcessSpec{Args: []string{"cat"}}},
+	}, out); err != nil {
+		t.Fatal(err)
+	}
+	got := <-ms
+	if end := got[len(got)-1]; !reflect.DeepEqual(end.ExitCodes, []int{NotStarted, 0}) {
+		t.Errorf("end %+v, want the first stage not started", end)
+	}
+}
+
+func TestPipelineKill(t *testing.T) {
+	unixTools(t)
+	out := make(chan *Message)
+	ms := collect(out)
+	pl, err := StartPipeline([]Step{
+		{Spec: &ProcessSpec{Args: []string{"sleep", "10"}}},
+		{Spec: &ProcessSpec{Args: []string{"cat"}}},
+	}, out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.AfterFunc(100*time.Millisecond, pl.Kill)
+	got := <-ms
+	end := got[len(got)-1]
+	if !strings.Contains(end.Body, "1: ") {
+		t.Errorf("end Body = %q, want the first stage reported", end.Body)
+	}
+}
+
+func TestPipelineRejects(t *testing.T) {
+	if _, err := StartPipeline(nil, nil); err == nil {
+		t.Error("empty Pipeline started")
+	}
+	_, err := StartPipeline([]Step{{Spec: &ProcessSpec{Args: []string{"cat"}, Stdin: true}}}, nil)
+	if err == nil {
+		t.Error("Pipeline with Stdin started")
+	}
+}
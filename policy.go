@@ -0,0 +1,181 @@
+// Copyright 2012 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package process
+
+import (
+	"errors"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// A Policy decides whether a program may be run. It is consulted before
+// a Process starts, ahead of its Preconditions and hooks, for the program
+// and for each Setup and Teardown hook.
+type Policy interface {
+	// Check is given the program's arguments, working directory and
+	// ProcessSpec.Env, and returns an error if it may not be run. It may
+	// change c to run something else instead; for a hook only changes to
+	// Args are kept.
+	Check(c *Command) error
+}
+
+// A Command is the program a Policy checks.
+type Command struct {
+	Args []string
+	Dir  string
+	Env  []string
+
+	// Runner is the ProcessSpec's Runner, if the program is to be run
+	// by one rather than on the server. It is nil for hooks.
+	Runner Runner
+}
+
+// DefaultPolicy, if not nil, is the Policy for Processes whose spec does
+// not have one, including those started with StartProcess. A server may
+// set it before starting any Processes.
+var DefaultPolicy Policy
+
+// checkPolicy applies spec's Policy, or DefaultPolicy, to spec and its
+// hooks, and returns the spec to run: spec itself, or a copy the Policy
+// changed.
+func checkPolicy(spec *ProcessSpec) (*ProcessSpec, error) {
+	policy := spec.Policy
+	if policy == nil {
+		policy = DefaultPolicy
+	}
+	if policy == nil {
+		return spec, nil
+	}
+	c := &Command{
+		Args:   append([]string(nil), spec.Args...),
+		Dir:    spec.Dir,
+		Env:    append([]string(nil), spec.Env...),
+		Runner: spec.Runner,
+	}
+	if err := policy.Check(c); err != nil {
+		return nil, err
+	}
+	if len(c.Args) == 0 {
+		return nil, errors.New("policy left no arguments")
+	}
+	s := *spec
+	s.Args, s.Dir, s.Env = c.Args, c.Dir, c.Env
+	for _, hooks := range []*[]Hook{&s.Setup, &s.Teardown} {
+		checked := make([]Hook, len(*hooks))
+		for i, h := range *hooks {
+			checked[i] = h
+			if len(h.Args) == 0 {
+				continue // reported by runHooks
+			}
+			c := &Command{
+				Args: append([]string(nil), h.Args...),
+				Dir:  s.Dir,
+				Env:  append([]string(nil), s.Env...),
+			}
+			if err := policy.Check(c); err != nil {
+				return nil, errors.New("hook: " + err.Error())
+			}
+			if len(c.Args) == 0 {
+				return nil, errors.New("policy left a hook no arguments")
+			}
+			h.Args = c.Args
+			checked[i] = h
+		}
+		*hooks = checked
+	}
+	return &s, nil
+}
+
+// ProgramPolicy is a Policy that allows only the programs in Allow, or,
+// if Allow is empty, every program not in Deny. Programs are named by
+// absolute path or, to be looked up in $PATH, by name alone.
+//
+// A program run on the server is looked up in the $PATH of the
+// ProcessSpec's Env, or else of the server, and then run by its absolute
+// path, so that it is the one checked whatever the Process' directory. A
+// program run by a Runner is on another machine, so its name must match
+// an entry in Allow or Deny as given, and is not changed.
+type ProgramPolicy struct {
+	Allow []string
+	Deny  []string
+}
+
+// Check allows or refuses c.Args[0] as described for ProgramPolicy.
+func (pp ProgramPolicy) Check(c *Command) error {
+	if c.Runner != nil {
+		name := c.Args[0]
+		if contains(pp.Deny, name) || len(pp.Allow) > 0 && !contains(pp.Allow, name) {
+			return errors.New("program " + name + " is not allowed")
+		}
+		return nil
+	}
+	path, err := programPath(c.Args[0], c.Dir, c.Env)
+	if err != nil {
+		return errors.New("program " + c.Args[0] + " is not allowed: " + err.Error())
+	}
+	if matchProgram(pp.Deny, path) || len(pp.Allow) > 0 && !matchProgram(pp.Allow, path) {
+		return errors.New("program " + c.Args[0] + " is not allowed")
+	}
+	c.Args[0] = path
+	return nil
+}
+
+// programPath returns the absolute path of the program name run in dir
+// with env, whose PATH, if it has one, is searched instead of the
+// server's.
+func programPath(name, dir string, env []string) (string, error) {
+	if strings.ContainsRune(name, filepath.Separator) || strings.ContainsRune(name, '/') {
+		if !filepath.IsAbs(name) {
+			name = filepath.Join(dir, name)
+		}
+		return filepath.Abs(name)
+	}
+	pathEnv, ok := "", false
+	for _, kv := range env {
+		if strings.HasPrefix(kv, "PATH=") {
+			pathEnv, ok = kv[len("PATH="):], true
+		}
+	}
+	if !ok {
+		path, err := exec.LookPath(name)
+		if err != nil {
+			return "", err
+		}
+		return filepath.Abs(path)
+	}
+	for _, d := range filepath.SplitList(pathEnv) {
+		if d == "" {
+			d = "."
+		}
+		if !filepath.IsAbs(d) {
+			d = filepath.Join(dir, d)
+		}
+		if path, err := exec.LookPath(filepath.Join(d, name)); err == nil {
+			return filepath.Abs(path)
+		}
+	}
+	return "", errors.New(name + " not found in the PATH of Env")
+}
+
+// matchProgram reports whether path is one of programs.
+func matchProgram(programs []string, path string) bool {
+	for _, prog := range programs {
+		if p, err := programPath(prog, "", nil); err == nil && p == path {
+			return true
+		}
+	}
+	return false
+}
+
+// contains reports whether list holds s.
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,100 @@
+package process
+
+import (
+	"errors"
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type denyAll struct{}
+
+func (denyAll) Check(c *Command) error { return errors.New("no") }
+
+func TestProgramPolicy(t *testing.T) {
+	unixTools(t)
+	echo, err := exec.LookPath("echo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pp := ProgramPolicy{Allow: []string{"echo"}}
+	c := &Command{Args: []string{"echo", "hi"}}
+	if err := pp.Check(c); err != nil || c.Args[0] != echo {
+		t.Errorf("Check(echo) = %v, Args %q; want echo run by path", err, c.Args)
+	}
+	for _, args := range [][]string{{"sh", "-c", "echo hi"}, {"./echo"}, {"no-such-program-anywhere"}} {
+		if err := pp.Check(&Command{Args: args, Dir: "/"}); err == nil {
+			t.Errorf("Check(%q) allowed it", args)
+		}
+	}
+	pp = ProgramPolicy{Deny: []string{"sh"}}
+	if err := pp.Check(&Command{Args: []string{"sh"}}); err == nil {
+		t.Errorf("Check(sh) allowed a denied program")
+	}
+	if err := pp.Check(&Command{Args: []string{"echo"}}); err != nil {
+		t.Errorf("Check(echo) = %v, want it allowed", err)
+	}
+
+	o := make(chan *Message)
+	c2 := collect(o)
+	p := StartProcessSpec(&ProcessSpec{
+		Args:   []string{"sh", "-c", "echo hi"},
+		Policy: ProgramPolicy{Allow: []string{"echo"}},
+	}, o)
+	if ms := <-c2; p != nil || !strings.Contains(ms[0].Body, "not allowed") {
+		t.Errorf("got %v, %+v; want the policy to refuse sh", p, ms[0])
+	}
+
+	DefaultPolicy = denyAll{}
+	defer func() { DefaultPolicy = nil }()
+	o = make(chan *Message)
+	c2 = collect(o)
+	if p := StartProcess(nil, []string{"echo"}, o); p != nil {
+		t.Errorf("StartProcess ran a program DefaultPolicy refuses")
+	}
+	<-c2
+}
+
+func TestProgramPolicyEnvPath(t *testing.T) {
+	unixTools(t)
+	dir := t.TempDir()
+	prog := filepath.Join(dir, "tool")
+	if err := ioutil.WriteFile(prog, []byte("#!/bin/sh\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	pp := ProgramPolicy{Allow: []string{prog}}
+	c := &Command{Args: []string{"tool"}, Env: []string{"PATH=" + dir}}
+	if err := pp.Check(c); err != nil || c.Args[0] != prog {
+		t.Errorf("Check(tool) = %v, Args %q; want %s from Env's PATH", err, c.Args, prog)
+	}
+	if err := pp.Check(&Command{Args: []string{"tool"}}); err == nil {
+		t.Errorf("Check(tool) found it without Env's PATH")
+	}
+}
+
+func TestProgramPolicyRunner(t *testing.T) {
+	pp := ProgramPolicy{Allow: []string{"python3"}}
+	c := &Command{Args: []string{"python3", "x.py"}, Runner: Docker{Image: "python"}}
+	if err := pp.Check(c); err != nil || c.Args[0] != "python3" {
+		t.Errorf("Check(python3) = %v, Args %q; want it allowed as is", err, c.Args)
+	}
+	if err := pp.Check(&Command{Args: []string{"/usr/bin/python3"}, Runner: Docker{Image: "python"}}); err == nil {
+		t.Errorf("Check allowed a program not named in Allow")
+	}
+}
+
+func TestPolicyHooks(t *testing.T) {
+	unixTools(t)
+	o := make(chan *Message)
+	c := collect(o)
+	p := StartProcessSpec(&ProcessSpec{
+		Args:   []string{"echo", "hi"},
+		Setup:  []Hook{{Args: []string{"sh", "-c", "echo setup"}}},
+		Policy: ProgramPolicy{Allow: []string{"echo"}},
+	}, o)
+	if ms := <-c; p != nil || !strings.Contains(ms[len(ms)-1].Body, "hook: program sh is not allowed") {
+		t.Errorf("got %v, %+v; want the policy to refuse the hook", p, ms[len(ms)-1])
+	}
+}
@@ -0,0 +1,81 @@
+// Copyright 2012 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package process
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"time"
+)
+
+// A Precondition reports whether something a program depends on is in
+// place, returning nil if it is and an error describing what is missing
+// if not.
+type Precondition func() error
+
+// pollInterval is how often unmet Preconditions are checked again.
+const pollInterval = 100 * time.Millisecond
+
+// PathExists holds once path exists.
+func PathExists(path string) Precondition {
+	return func() error {
+		_, err := os.Stat(path)
+		return err
+	}
+}
+
+// PortOpen holds once a TCP connection to addr can be made.
+func PortOpen(addr string) Precondition {
+	return func() error {
+		c, err := net.DialTimeout("tcp", addr, pollInterval)
+		if err != nil {
+			return err
+		}
+		c.Close()
+		return nil
+	}
+}
+
+// PortClosed holds once nothing accepts TCP connections on addr.
+func PortClosed(addr string) Precondition {
+	return func() error {
+		if PortOpen(addr)() == nil {
+			return errors.New(addr + " is still accepting connections")
+		}
+		return nil
+	}
+}
+
+// waitFor polls conds until they all hold, timeout has passed or ctx is
+// canceled, and returns the error of the first one still failing at that
+// point.
+func waitFor(ctx context.Context, conds []Precondition, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		err := checkAll(conds)
+		if err == nil {
+			return nil
+		}
+		if !time.Now().Before(deadline) {
+			return errors.New("precondition not met: " + err.Error())
+		}
+		select {
+		case <-time.After(pollInterval):
+		case <-ctx.Done():
+			return errCanceled
+		}
+	}
+}
+
+func checkAll(conds []Precondition) error {
+	for _, c := range conds {
+		if err := c(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package process
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPreconditions(t *testing.T) {
+	unixTools(t)
+	path := filepath.Join(t.TempDir(), "ready")
+	go func() {
+		time.Sleep(3 * pollInterval)
+		ioutil.WriteFile(path, nil, 0666)
+	}()
+	o := make(chan *Message)
+	c := collect(o)
+	p := StartProcessSpec(&ProcessSpec{
+		Args:                []string{"true"},
+		Preconditions:       []Precondition{PathExists(path)},
+		PreconditionTimeout: time.Minute,
+	}, o)
+	if p == nil {
+		t.Fatalf("StartProcessSpec failed: %+v", <-c)
+	}
+	<-p.Done
+	<-c
+
+	o = make(chan *Message)
+	c = collect(o)
+	p = StartProcessSpec(&ProcessSpec{
+		Args:                []string{"true"},
+		Preconditions:       []Precondition{PathExists(path + ".missing")},
+		PreconditionTimeout: 2 * pollInterval,
+	}, o)
+	ms := <-c
+	if p != nil || len(ms) != 1 || !strings.HasPrefix(ms[0].Body, "precondition not met") {
+		t.Errorf("got %v, %+v; want nil and an unmet precondition", p, ms)
+	}
+}
@@ -0,0 +1,32 @@
+// Copyright 2012 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build unix
+
+package process
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// LockFree holds once no process holds an flock(2) lock on path. A
+// missing file counts as free.
+func LockFree(path string) Precondition {
+	return func() error {
+		f, err := os.Open(path)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+			return errors.New(path + " is locked")
+		}
+		return syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+	}
+}
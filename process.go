@@ -12,138 +12,1078 @@
 package process
 
 import (
-	"os/exec"
+	"context"
 	"errors"
-
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
 )
 
-const msgLimit = 1000 // max number of messages to send per session
+// NotStarted is the code in Message.ExitCodes of a Pipeline stage that
+// could not be started, as distinct from -1 for one that did not exit
+// normally.
+const NotStarted = -2
 
 // Message is the wire format for the websocket connection to the browser.
 // It is used for both sending output messages and receiving commands, as
 // distinguished by the Kind field.
 type Message struct {
-	Id   string // client-provided unique id for the Process
-	Kind string // in: "run", "kill" out: "stdout", "stderr", "end"
+	Id string // client-provided unique id for the Process
+
+	// Kind is one of
+	//	in:  "run", "kill", "pause", "resume", "signal", "stdin", "stdin-eof",
+	//	     "resize"
+	//	out: "queued", "started", "stdout", "stderr", "progress", "summary",
+	//	     "restarting", "end"
+	Kind string
 	Body string
+
+	// Label names the part of a run that produced an output Message,
+	// such as "setup" or "teardown". It is empty for the program itself.
+	Label string `json:",omitempty"`
+
+	// Progress is set in "progress" Messages, which follow the output
+	// Message holding the line of output they were parsed from, and
+	// carry that line as their Body.
+	Progress *Progress `json:",omitempty"`
+
+	// The remaining fields describe how a Process ended and are only set
+	// in "end" Messages. ExitCode is the program's exit status, or -1 if
+	// it did not exit normally. Signal names the signal that terminated
+	// it, such as "SIGKILL". Reason says why it ended:
+	//	"exited"          the program exited by itself, whatever its status
+	//	"signaled"        it was terminated by a signal from elsewhere
+	//	"killed"          Kill was called or a "kill" Message handled
+	//	"preempted"       a Manager killed it to run a more urgent Process
+	//	"canceled"        the context passed to StartProcessContext was canceled
+	//	"output-limit"    it sent more Messages than its OutputLimit
+	//	"cpu-limit"       it used up its Limits.CPU
+	//	"file-size-limit" it tried to write a file larger than Limits.FileSize
+	//	"oom-killed"      a process in its Cgroup used more than Cgroup.Memory
+	//	"start-failed"    it could not be started
+	//	"build-failed"    the build step of a Build failed, so it was not run
+	// Termination says how a stopped Process was stopped: "graceful" if
+	// it exited within the grace period after its StopSignal, "forced" if
+	// it was killed. Stats counts the data the program exchanged. Leaked
+	// counts the processes the program started and left running when it
+	// exited, which were then killed. FDs describes the file descriptors
+	// it used, if its ProcessSpec asked for them to be sampled.
+	// Fingerprint is a digest of the program file, its arguments and
+	// environment and the limits it ran under, so that two runs with the
+	// same Fingerprint can be taken to have run the same way.
+	// ExitCodes is only set for a Pipeline, and holds the exit code of
+	// each stage in order, as for ExitCode, or NotStarted for a stage
+	// that could not be started; ExitCode is then the last stage's.
+	ExitCode    int      `json:",omitempty"`
+	Signal      string   `json:",omitempty"`
+	Reason      string   `json:",omitempty"`
+	Termination string   `json:",omitempty"`
+	Stats       *Stats   `json:",omitempty"`
+	Leaked      int      `json:",omitempty"`
+	FDs         *FDStats `json:",omitempty"`
+	Fingerprint string   `json:",omitempty"`
+	ExitCodes   []int    `json:",omitempty"`
+}
+
+// messagePool holds Messages for reuse on the output path, which allocates
+// one per write by every running child.
+var messagePool = sync.Pool{New: func() interface{} { return new(Message) }}
+
+// newMessage returns a Message from messagePool with the given fields.
+func newMessage(id, kind, body string) *Message {
+	m := messagePool.Get().(*Message)
+	*m = Message{Id: id, Kind: kind, Body: body}
+	return m
+}
+
+// Release returns m to the pool used for Process output Messages.
+// Consumers that are done with a Message they received may call Release
+// to reduce allocation when many Processes stream output; m must not be
+// used after that. Releasing is optional.
+func (m *Message) Release() {
+	*m = Message{}
+	messagePool.Put(m)
 }
 
 // Process represents a running Process.
 type Process struct {
-	id   string
-	out  chan<- *Message
-	Done chan struct{} // closed when wait completes
-	run  *exec.Cmd
+	id    string
+	out   chan<- *Message
+	Done  chan struct{} // closed when wait completes
+	run   Run
+	spec  *ProcessSpec // the spec run was started from
+	stdin io.WriteCloser
+
+	pty     *os.File      // master side of the terminal in PTY mode
+	ptyDone chan struct{} // closed when all terminal output has been sent
+
+	secrets []string          // "NAME=value" pairs for Secrets and Credentials
+	redact  *strings.Replacer // replaces their values in output
+	parsers []ProgressParser  // see ProcessSpec.Progress
+
+	// pipeIn and pipeOut, if not nil, are the program's standard input
+	// and output, connecting it to the other stages of a Pipeline.
+	pipeIn, pipeOut *os.File
+
+	fingerprint  string // see Message.Fingerprint
+	stopSampling func() // ends sampleFDs, if it was started
+
+	started chan struct{} // closed once start has been attempted
+	cleanup []func()      // undoes start; see onRelease
+	errs    chan error    // see Err
+	faults  *Faults
+
+	mu        sync.Mutex
+	reason    string      // why the Process was stopped, if it did not end by itself
+	stopHooks func()      // kills the hooks running, if any
+	stopErr   error       // reported in place of the program's exit status
+	how       string      // "graceful" or "forced" once stopping has begun
+	oomKilled bool        // whether the kernel killed a process in the cgroup
+	begin     time.Time   // when the program was started
+	exit      time.Time   // when it exited
+	status    *ExitStatus // how it ended, once it has
+	job       uintptr     // Windows Job Object holding the program, or 0
+	fds       *FDStats    // see Message.FDs
+
+	stdinN, stdoutN, stderrN atomic.Int64 // see Stats
+}
+
+// newProcess returns a Process, not yet started, that sends its Messages
+// on out.
+func newProcess(out chan<- *Message) *Process {
+	return &Process{
+		id:      newID(),
+		out:     out,
+		Done:    make(chan struct{}),
+		started: make(chan struct{}),
+		errs:    make(chan error, errBuffer),
+		faults:  currentFaults(),
+	}
+}
+
+// ProcessSpec describes a program to run and the conditions to run it
+// under.
+type ProcessSpec struct {
+	Dir  string   // working directory; "" means the server's own
+	Args []string // program name and arguments
+
+	// The program inherits the server's environment, except for the
+	// variables named in StripEnv, or nothing at all if ClearEnv is set.
+	// A StripEnv entry ending in "*" matches every name with that prefix,
+	// as in "AWS_*". Env holds "NAME=value" entries added to the result,
+	// replacing inherited variables of the same name.
+	Env      []string
+	StripEnv []string
+	ClearEnv bool
+
+	// Locale, if set, is given to the program as LANG and LC_ALL, and TZ
+	// as TZ, overriding Env. Locale must be one of Locales, and TZ a name
+	// from the time zone database such as "UTC" or "Europe/Paris".
+	Locale string
+	TZ     string
+
+	// Preconditions must all hold before the program is executed. They
+	// are polled until PreconditionTimeout has passed; if it is zero they
+	// are checked only once.
+	Preconditions       []Precondition
+	PreconditionTimeout time.Duration
+
+	// Processes with the same non-empty Lock run one at a time: starting
+	// one waits until the previous one has exited, or fails with
+	// ErrLocked if LockNoWait is set.
+	Lock       string
+	LockNoWait bool
+
+	// Setup hooks run in order before the program and Teardown hooks
+	// after it, even if it fails, is killed or cannot be started once
+	// the Setup hooks have succeeded. Their output is labelled "setup" or
+	// "teardown". Killing the Process, or canceling its context, kills
+	// the Setup hooks; Teardown hooks are only killed by killing the
+	// Process while they run.
+	Setup, Teardown []Hook
+
+	// If Stdin is set, the program's standard input is a pipe fed by
+	// "stdin" Messages and closed by a "stdin-eof" Message. Otherwise it
+	// reads from the null device.
+	Stdin bool
+
+	// If PTY is set, the program runs in a new session on a new
+	// pseudo-terminal, so that it sees a terminal on its standard input,
+	// output and error. Everything it writes is sent as "stdout", and
+	// "stdin" Messages are accepted whether or not Stdin is set; a
+	// "stdin-eof" Message types the terminal's end-of-file character.
+	// PTY mode is only supported on Linux.
+	PTY bool
+
+	// If GracePeriod is positive, stopping the Process sends StopSignal,
+	// or SIGTERM if that is nil, to the program's process group, and only
+	// kills the group if the program has not exited after GracePeriod.
+	// Otherwise it is killed straight away.
+	StopSignal  os.Signal
+	GracePeriod time.Duration
+
+	// If Isolation is not nil, the program runs in the new namespaces it
+	// selects.
+	Isolation *Isolation
+
+	// If User is not nil, the program runs with its user and group ids
+	// rather than the server's. Hooks still run as the server.
+	User *User
+
+	// Limits caps the resources the program may use. Hooks are not
+	// limited.
+	Limits Limits
+
+	// OutputLimit, if positive, is how many Messages the Process may
+	// send before its program is killed for producing too much output.
+	// Later Messages are dropped, but for the "end" Message.
+	OutputLimit int
+
+	// If Cgroup is not nil, the program runs in a new cgroup as it
+	// describes.
+	Cgroup *Cgroup
+
+	// If Seccomp is not nil, the program runs under it. The server binary
+	// is executed to install the filter before executing the program, so
+	// it must import this package and must not rely on its own init
+	// functions being free of side effects.
+	Seccomp SeccompProfile
+
+	// Secrets maps environment variable names to the names of secrets,
+	// which are looked up in SecretProvider when the Process starts and
+	// added to the program's environment. Secret values are replaced by
+	// "[redacted]" in output Messages, as long as the program does not
+	// split one across two writes.
+	Secrets        map[string]string
+	SecretProvider SecretProvider
+
+	// Runner, if not nil, runs the program in place of the server. PTY,
+	// Cgroup, Seccomp and Isolation are not supported with a Runner.
+	Runner Runner
+
+	// Credentials are minted when the Process starts and revoked when
+	// it ends, after any Teardown hooks. Like Secrets, they are added to
+	// the environment and redacted from output.
+	Credentials []CredentialMinter
+
+	// Progress lists parsers for output lines reporting progress, such as
+	// GoTestProgress. For each line one recognizes, in the output of the
+	// program or its hooks, a "progress" Message is sent.
+	Progress []ProgressParser
+
+	// Policy, if not nil, decides whether the program may run, in place
+	// of DefaultPolicy.
+	Policy Policy
+
+	// Priority orders the Process in a Manager's queue: the higher it
+	// is, the sooner the Process starts.
+	Priority int
+
+	// FDSampleInterval, if positive, is how often the file descriptors
+	// the program has open are counted, for the FDs field of the "end"
+	// Message. It is only supported on Linux, without a Runner.
+	FDSampleInterval time.Duration
+
+	// If RestartOnFailure is set, a Supervisor running the spec starts
+	// the program again whenever it fails, up to MaxRestarts times if
+	// that is positive. It waits Backoff, or a second if that is zero,
+	// before the first restart, and twice as long before each one after,
+	// but never more than a minute.
+	RestartOnFailure bool
+	MaxRestarts      int
+	Backoff          time.Duration
+}
+
+// newSpec returns the ProcessSpec for a directory and argument list as
+// passed to StartProcess.
+func newSpec(dir *string, args []string) *ProcessSpec {
+	s := &ProcessSpec{Args: args}
+	if dir != nil {
+		s.Dir = *dir
+	}
+	return s
 }
 
 // startProcess builds and runs the given program, sending its output
 // and end event as Messages on the provided channel.
 func StartProcess(dir *string, args []string, out chan<- *Message) *Process {
-	p := &Process{
-		id:   string(<-uniq),
-		out:  out,
-		Done: make(chan struct{}),
+	return StartProcessSpec(newSpec(dir, args), out)
+}
+
+// StartProcessContext is like StartProcess, but if ctx is canceled before
+// the program exits, the program is killed and the "end" Message reports
+// "canceled".
+func StartProcessContext(ctx context.Context, dir *string, args []string, out chan<- *Message) *Process {
+	return startSpec(ctx, newSpec(dir, args), out)
+}
+
+// StartProcessSpec is like StartProcess but takes the program to run and
+// its options as a ProcessSpec. It blocks while waiting for the spec's
+// Preconditions and Lock and while running its Setup hooks.
+func StartProcessSpec(spec *ProcessSpec, out chan<- *Message) *Process {
+	return startSpec(context.Background(), spec, out)
+}
+
+func startSpec(ctx context.Context, spec *ProcessSpec, out chan<- *Message) *Process {
+	return newProcess(out).launch(ctx, spec)
+}
+
+// launch starts p as startSpec does, returning p, or nil if it could not
+// be started.
+func (p *Process) launch(ctx context.Context, spec *ProcessSpec) *Process {
+	out, passed := p.out, (<-chan struct{})(nil)
+	if spec.OutputLimit > 0 {
+		p.out, passed = limiter(spec.OutputLimit, p.stop, out)
 	}
-	if err := p.start(dir, args); err != nil {
+	err := p.start(ctx, spec)
+	close(p.started)
+	if err != nil {
 		p.end(err)
+		if passed != nil {
+			<-passed
+		}
 		close(out)
+		close(p.Done)
+		close(p.errs)
 		return nil
 	}
 	go p.wait()
 	return p
 }
 
+// StartAsync is like StartProcess but returns without waiting for the
+// program to be executed. Once it is running, a "started" Message is sent
+// whose Body holds its pid and start time, separated by a space, before
+// any output. If it cannot be started, only the "end" Message is sent,
+// out is closed, and Done is closed.
+func StartAsync(dir *string, args []string, out chan<- *Message) *Process {
+	p := newProcess(out)
+	go func() {
+		if err := p.start(context.Background(), newSpec(dir, args)); err != nil {
+			close(p.started)
+			p.fail(err)
+			p.end(err)
+			close(out)
+			close(p.Done)
+			close(p.errs)
+			return
+		}
+		body := p.run.ID() + " " + time.Now().Format(time.RFC3339Nano)
+		p.out <- newMessage(p.id, "started", body)
+		close(p.started)
+		p.wait()
+	}()
+	return p
+}
+
+// String returns a description of the Process for logging.
+func (p *Process) String() string {
+	return "process " + p.id
+}
+
 // Kill stops the Process if it is running and waits for it to exit.
+// Processes the program started are killed with it: on Unix the program
+// runs in a process group of its own, and on Windows in a Job Object.
 func (p *Process) Kill() {
 	if p == nil {
 		return
 	}
-	p.run.Process.Kill()
+	p.stop("killed", nil)
+}
+
+// stop kills the Process and waits for it to exit, recording reason as
+// the cause of its end and, if err is not nil, reporting err in the "end"
+// Message in place of the error from waiting for the program.
+func (p *Process) stop(reason string, err error) {
+	p.mu.Lock()
+	if p.reason == "" {
+		p.reason, p.stopErr = reason, err
+	}
+	stopHooks := p.stopHooks
+	p.mu.Unlock()
+	if stopHooks != nil {
+		stopHooks()
+	}
+	<-p.started
+	time.Sleep(p.faults.KillDelay)
+	if p.run != nil {
+		p.terminate()
+	}
 	<-p.Done // block until Process exits
 }
 
+// stopping reports whether stop has been called.
+func (p *Process) stopping() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.reason != ""
+}
+
+// terminate ends the running program as its spec's StopSignal and
+// GracePeriod say, unless another call is already doing so.
+func (p *Process) terminate() {
+	p.mu.Lock()
+	if p.how != "" {
+		p.mu.Unlock()
+		return
+	}
+	p.how = "forced"
+	if p.spec.GracePeriod > 0 {
+		p.how = "graceful"
+	}
+	p.mu.Unlock()
+	if p.spec.GracePeriod > 0 {
+		sig := p.spec.StopSignal
+		if sig == nil {
+			sig = syscall.SIGTERM
+		}
+		if p.run.Signal(sig) == nil {
+			t := time.NewTimer(p.spec.GracePeriod)
+			defer t.Stop()
+			select {
+			case <-p.Done:
+				return
+			case <-t.C:
+			}
+		}
+		// Record the escalation before killing, so that the "end"
+		// Message cannot miss it.
+		p.mu.Lock()
+		p.how = "forced"
+		p.mu.Unlock()
+	}
+	p.run.Kill()
+}
+
+// Pause suspends the running Process until Resume is called.
+func (p *Process) Pause() error {
+	if err := p.running(); err != nil {
+		return err
+	}
+	return p.pause()
+}
+
+// Resume continues a Process suspended by Pause.
+func (p *Process) Resume() error {
+	if err := p.running(); err != nil {
+		return err
+	}
+	return p.resume()
+}
+
+// running waits for the Process to be started and reports an error if it
+// could not be.
+func (p *Process) running() error {
+	<-p.started
+	if p.run == nil {
+		return errors.New("process not started")
+	}
+	return nil
+}
+
+// Signal sends sig to the running program.
+func (p *Process) Signal(sig os.Signal) error {
+	if err := p.running(); err != nil {
+		return err
+	}
+	return p.run.Signal(sig)
+}
+
+// Write writes b to the standard input of a Process started with
+// ProcessSpec.Stdin set. It blocks until the program has read enough of
+// its input to make room for b.
+func (p *Process) Write(b []byte) (int, error) {
+	if err := p.running(); err != nil {
+		return 0, err
+	}
+	if p.stdin == nil {
+		return 0, errNoStdin
+	}
+	n, err := p.stdin.Write(b)
+	p.stdinN.Add(int64(n))
+	return n, err
+}
+
+// CloseStdin closes the standard input of a Process started with
+// ProcessSpec.Stdin set, so that the program reads end of file.
+func (p *Process) CloseStdin() error {
+	if err := p.running(); err != nil {
+		return err
+	}
+	if p.stdin == nil {
+		return errNoStdin
+	}
+	return p.stdin.Close()
+}
+
+var errNoStdin = errors.New("process has no standard input pipe")
+
+// Resize sets the window size of the terminal of a Process started with
+// ProcessSpec.PTY set. The program is sent SIGWINCH.
+func (p *Process) Resize(rows, cols int) error {
+	if err := p.running(); err != nil {
+		return err
+	}
+	if p.pty == nil {
+		return errors.New("process has no terminal")
+	}
+	if rows <= 0 || cols <= 0 || rows > 0xffff || cols > 0xffff {
+		return errors.New("bad terminal size")
+	}
+	return setSize(p.pty, rows, cols)
+}
+
+// Handle carries out a command Message sent by the client for this
+// Process. Kinds other than "kill", "pause", "resume", "signal", "stdin",
+// "stdin-eof" and "resize" are reported as a *ProtocolError. The Body of
+// a "signal" Message names the signal to send, such as "SIGHUP", and the
+// Body of a "resize" Message holds the number of rows and columns
+// separated by a space, such as "24 80".
+func (p *Process) Handle(m *Message) error {
+	switch m.Kind {
+	case "kill":
+		p.Kill()
+		return nil
+	case "pause":
+		return p.Pause()
+	case "resume":
+		return p.Resume()
+	case "signal":
+		sig, ok := signalByName(m.Body)
+		if !ok {
+			return &ProtocolError{"Body", "unknown signal " + strconv.Quote(m.Body)}
+		}
+		return p.Signal(sig)
+	case "stdin":
+		_, err := io.WriteString(p, m.Body)
+		return err
+	case "stdin-eof":
+		return p.CloseStdin()
+	case "resize":
+		f := strings.Fields(m.Body)
+		if len(f) != 2 {
+			return &ProtocolError{"Body", "resize wants rows and columns"}
+		}
+		rows, err1 := strconv.Atoi(f[0])
+		cols, err2 := strconv.Atoi(f[1])
+		if err1 != nil || err2 != nil {
+			return &ProtocolError{"Body", "bad terminal size " + strconv.Quote(m.Body)}
+		}
+		return p.Resize(rows, cols)
+	}
+	return &ProtocolError{"Kind", "cannot handle " + strconv.Quote(m.Kind)}
+}
+
 // start builds and starts the given program, sending its output to p.out,
-// and stores the running *exec.Cmd in the run field.
-func (p *Process) start(dir *string, args []string) error {
+// and stores the running program in the run field. The program is
+// stopped if ctx is canceled.
+func (p *Process) start(ctx context.Context, spec *ProcessSpec) (err error) {
 
-	if len(args) == 0 {
+	if len(spec.Args) == 0 {
 		return errors.New("No arguments found")
 	}
-	cmd := p.cmd(dir, args...)
+	if p.faults.StartErr != nil {
+		return p.faults.StartErr
+	}
+	if spec, err = checkPolicy(spec); err != nil {
+		return err
+	}
+	if err := checkLocale(spec); err != nil {
+		return err
+	}
+	if err := spec.User.check(); err != nil {
+		return err
+	}
+	if iso := spec.Isolation; iso != nil && iso.Rootfs != "" && (spec.User == nil || spec.User.Uid == 0) {
+		return errors.New("Isolation.Rootfs needs a User other than root")
+	}
+	if err := waitFor(ctx, spec.Preconditions, spec.PreconditionTimeout); err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			p.release()
+		}
+	}()
+	if spec.Lock != "" {
+		unlock, err := lock(ctx, spec.Lock, spec.LockNoWait)
+		if err != nil {
+			return err
+		}
+		p.onRelease(unlock)
+	}
+	if err := p.fetchSecrets(spec); err != nil {
+		return err
+	}
+	p.parsers = spec.Progress
+	if p.stopping() {
+		return errStopped
+	}
+	if err := p.runHooks(ctx, spec, spec.Setup, "setup"); err != nil {
+		if ctx.Err() != nil {
+			return errCanceled
+		}
+		return err
+	}
+	defer func() {
+		if err != nil {
+			// Undo what the Setup hooks did, even though the program
+			// never ran.
+			p.runHooks(context.Background(), spec, spec.Teardown, "teardown")
+		}
+	}()
+	if ctx.Err() != nil {
+		return errCanceled
+	}
+	if p.stopping() {
+		return errStopped
+	}
+	p.fingerprint = fingerprint(spec)
+	if spec.Runner != nil {
+		err = p.startRunner(spec)
+	} else {
+		err = p.startExec(spec)
+	}
+	if err != nil {
+		return err
+	}
+	p.mu.Lock()
+	p.begin = time.Now()
+	p.mu.Unlock()
+	p.spec = spec
+	if ctx.Done() != nil {
+		go func() {
+			select {
+			case <-ctx.Done():
+				p.stop("canceled", errCanceled)
+			case <-p.Done:
+			}
+		}()
+	}
+	return nil
+}
+
+// startExec starts the program of spec on the server.
+func (p *Process) startExec(spec *ProcessSpec) error {
+	cmd := p.cmd(spec, spec.Args)
+	if p.pipeIn != nil {
+		cmd.Stdin = p.pipeIn
+	}
+	if p.pipeOut != nil {
+		cmd.Stdout = p.pipeOut
+	}
+	h := &helper{Limits: spec.Limits, Nice: niceness(spec)}
+	if spec.Isolation != nil {
+		if err := isolate(cmd, spec.Isolation, h); err != nil {
+			return err
+		}
+	}
+	if spec.User != nil {
+		if err := setUser(cmd, spec.User); err != nil {
+			return err
+		}
+	}
+	if spec.Cgroup != nil {
+		if err := p.useCgroup(cmd, spec.Cgroup); err != nil {
+			return err
+		}
+	}
+	if spec.Seccomp != nil {
+		if err := useSeccomp(h, spec.Seccomp); err != nil {
+			return err
+		}
+	}
+	helperStarted := func() error { return nil }
+	if h.needed() {
+		// Find out now if the program cannot be run, as the helper only
+		// can once it has started.
+		if err := checkProgram(h.Root, cmd); err != nil {
+			return err
+		}
+		var err error
+		if helperStarted, err = p.useHelper(cmd, h); err != nil {
+			return err
+		}
+	}
+	if spec.FDSampleInterval > 0 {
+		// Check that descriptors can be read here at all.
+		if _, err := readFDs(os.Getpid()); err != nil {
+			return err
+		}
+	}
+	var err error
+	ptyStarted := func() {}
+	switch {
+	case spec.PTY:
+		if ptyStarted, err = p.usePTY(cmd); err != nil {
+			return err
+		}
+	case spec.Stdin:
+		stdin, err := cmd.StdinPipe()
+		if err != nil {
+			return err
+		}
+		p.stdin = stdin
+	}
+	run := &execRun{p: p, cmd: cmd}
+	if !spec.PTY {
+		if ptyStarted, run.copied, err = p.pipeOutput(cmd); err != nil {
+			return err
+		}
+	}
+	newGroup(cmd)
 	if err := cmd.Start(); err != nil {
+		return diagnose(cmd, err)
+	}
+	if err := helperStarted(); err != nil {
+		cmd.Wait()
 		return err
 	}
-	p.run = cmd
+	ptyStarted()
+	if err := p.track(cmd); err != nil {
+		p.fail(err)
+	}
+	if spec.FDSampleInterval > 0 {
+		p.stopSampling = p.sampleFDs(cmd.Process.Pid, spec.FDSampleInterval)
+	}
+	p.run = run
 	return nil
 }
 
+// pipeOutput connects cmd's standard output and error to its writers
+// through pipes, rather than leaving exec to copy them, so that cmd.Wait
+// returns once the program exits even if processes it started still
+// hold them open. The returned function must be called once cmd has
+// started; the channel is closed when all the output has been copied.
+func (p *Process) pipeOutput(cmd *exec.Cmd) (started func(), copied <-chan struct{}, err error) {
+	var files []*os.File
+	p.onRelease(func() {
+		for _, f := range files {
+			f.Close()
+		}
+	})
+	var writers []io.Writer
+	var readers, ends []*os.File
+	for _, w := range []*io.Writer{&cmd.Stdout, &cmd.Stderr} {
+		if _, ok := (*w).(*os.File); ok {
+			continue // a Pipeline's pipe, handed to the program as is
+		}
+		r, end, err := os.Pipe()
+		if err != nil {
+			return nil, nil, err
+		}
+		files = append(files, r, end)
+		writers = append(writers, *w)
+		readers = append(readers, r)
+		ends = append(ends, end)
+		*w = end
+	}
+	done := make(chan struct{})
+	return func() {
+		for _, end := range ends {
+			end.Close()
+		}
+		var wg sync.WaitGroup
+		for i, w := range writers {
+			wg.Add(1)
+			go func(w io.Writer, r *os.File) {
+				defer wg.Done()
+				if _, err := io.Copy(w, r); err != nil {
+					p.fail(err)
+				}
+			}(w, readers[i])
+		}
+		go func() {
+			wg.Wait()
+			close(done)
+		}()
+	}, done, nil
+}
+
+// onRelease arranges for f to be called when the Process ends or fails
+// to start, after any Teardown hooks. Functions run in reverse order.
+func (p *Process) onRelease(f func()) {
+	p.cleanup = append(p.cleanup, f)
+}
+
+// release runs the functions registered with onRelease.
+func (p *Process) release() {
+	for i := len(p.cleanup) - 1; i >= 0; i-- {
+		p.cleanup[i]()
+	}
+	p.cleanup = nil
+}
+
+// errStopped is the start error of a Process stopped before its program
+// could be started.
+var errStopped = errors.New("stopped before the program started")
+
+// errCanceled is the start error of a Process whose context was canceled
+// before its program could be started.
+var errCanceled = errors.New("canceled")
+
 // wait waits for the running Process to complete
 // and sends its error state to the client.
 func (p *Process) wait() {
-	p.end(p.run.Wait())
+	status, err := p.run.Wait()
+	if p.stopSampling != nil {
+		p.stopSampling()
+	}
+	p.mu.Lock()
+	p.exit = time.Now()
+	p.status = &status
+	p.mu.Unlock()
+	if err != nil {
+		p.fail(err)
+	} else {
+		err = status.err()
+	}
+	if p.ptyDone != nil {
+		<-p.ptyDone
+	}
+	p.mu.Lock()
+	if p.stopErr != nil {
+		err = p.stopErr
+	}
+	p.mu.Unlock()
+	if herr := p.runHooks(context.Background(), p.spec, p.spec.Teardown, "teardown"); err == nil {
+		err = herr
+	}
+	p.release()
+	p.end(err)
 	close(p.Done) // unblock waiting Kill calls
+	close(p.errs)
+}
+
+// errBuffer is the number of errors Err holds for a slow reader.
+const errBuffer = 4
+
+// Err returns a channel carrying errors in running the Process itself,
+// such as a failure to start it or to copy its output, as opposed to the
+// program's own exit status or stderr. The channel is closed when Done
+// is. Errors are dropped if several are already waiting to be received.
+func (p *Process) Err() <-chan error {
+	return p.errs
+}
+
+// fail reports err on the Err channel if there is room.
+func (p *Process) fail(err error) {
+	select {
+	case p.errs <- err:
+	default:
+	}
 }
 
 // end sends an "end" message to the client, containing the Process id and the
 // given error value.
 func (p *Process) end(err error) {
-	m := &Message{Id: p.id, Kind: "end"}
+	m := newMessage(p.id, "end", "")
 	if err != nil {
 		m.Body = err.Error()
 	}
+	p.mu.Lock()
+	m.Reason = p.reason
+	m.Termination = p.how
+	oom := p.oomKilled
+	status := p.status
+	fds := p.fds
+	p.mu.Unlock()
+	if status == nil {
+		switch {
+		case m.Reason != "":
+		case err == errCanceled:
+			m.Reason = "canceled"
+		default:
+			m.Reason = "start-failed"
+		}
+		p.out <- m
+		return
+	}
+	m.ExitCode = status.Code
+	m.Signal = status.Signal
+	st := p.Stats()
+	m.Stats = &st
+	m.Leaked = status.Leaked
+	m.FDs = fds
+	m.Fingerprint = p.fingerprint
+	if m.Reason == "" && oom {
+		m.Reason = "oom-killed"
+	}
+	if m.Reason == "" {
+		m.Reason = status.Reason
+	}
+	if m.Reason == "" {
+		m.Reason = limitReason(p.spec.Limits, *status)
+	}
+	if m.Reason == "" {
+		m.Reason = "exited"
+		if m.Signal != "" {
+			m.Reason = "signaled"
+		}
+	}
 	p.out <- m
 }
 
-// cmd builds an *exec.Cmd that writes its standard output and error to the
-// Process' output channel.
-func (p *Process) cmd(dir *string, args ...string) *exec.Cmd {
-	cmd := exec.Command(args[0], args[1:]...)
-	if dir != nil {
-		cmd.Dir = *dir
+// cmd builds an *exec.Cmd running args, the program of spec, that writes
+// its standard output and error to the Process' output channel.
+func (p *Process) cmd(spec *ProcessSpec, args []string) *exec.Cmd {
+	cmd := p.command(context.Background(), spec, args)
+	cmd.Stdout = p.writer("stdout", "", p.started)
+	cmd.Stderr = p.writer("stderr", "", p.started)
+	return cmd
+}
+
+// execRun is a program run on the server, the default.
+type execRun struct {
+	p      *Process
+	cmd    *exec.Cmd
+	copied <-chan struct{} // closed when all output has been copied, if not in PTY mode
+}
+
+func (r *execRun) ID() string {
+	return strconv.Itoa(r.cmd.Process.Pid)
+}
+
+func (r *execRun) Wait() (ExitStatus, error) {
+	err := r.cmd.Wait()
+	if _, ok := err.(*exec.ExitError); ok {
+		err = nil
 	}
-	cmd.Stdout = &messageWriter{p.id, "stdout", p.out}
-	cmd.Stderr = &messageWriter{p.id, "stderr", p.out}
+	// Kill what the program left behind before waiting for its output,
+	// which those processes may be holding open.
+	leaked := r.killLeaked()
+	if r.copied != nil {
+		<-r.copied
+	}
+	s := r.cmd.ProcessState
+	if s == nil {
+		return ExitStatus{Code: -1}, err
+	}
+	return ExitStatus{
+		Code:   s.ExitCode(),
+		Signal: signalName(s),
+		CPU:    s.UserTime() + s.SystemTime(),
+		MaxRSS: maxRSS(s),
+		Leaked: leaked,
+	}, err
+}
+
+// command returns an *exec.Cmd running args with the settings in spec,
+// which is killed if ctx is done before it exits.
+func (p *Process) command(ctx context.Context, spec *ProcessSpec, args []string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Dir = spec.Dir
+	cmd.Env = environ(spec, p.secrets)
 	return cmd
 }
 
+// writer returns a messageWriter for output of the given kind and label,
+// which waits for ready to be closed before sending anything.
+func (p *Process) writer(kind, label string, ready <-chan struct{}) *messageWriter {
+	return &messageWriter{
+		id:      p.id,
+		kind:    kind,
+		label:   label,
+		out:     p.out,
+		ready:   ready,
+		redact:  p.redact,
+		parsers: p.parsers,
+		faults:  p.faults,
+		count:   p.counter(kind, label),
+	}
+}
+
+// counter returns the Stats counter for output of the given kind and
+// label, or nil if it is not counted.
+func (p *Process) counter(kind, label string) *atomic.Int64 {
+	switch {
+	case label != "":
+		return nil
+	case kind == "stdout":
+		return &p.stdoutN
+	case kind == "stderr":
+		return &p.stderrN
+	}
+	return nil
+}
+
 // messageWriter is an io.Writer that converts all writes to Message sends on
 // the out channel with the specified id and kind.
 type messageWriter struct {
 	id, kind string
+	label    string
 	out      chan<- *Message
+	ready    <-chan struct{}   // closed when output may be sent; nil if now
+	redact   *strings.Replacer // hides secret values; may be nil
+	parsers  []ProgressParser
+	partial  string // incomplete last line, for parsers
+
+	count *atomic.Int64 // bytes written, if not nil
+
+	faults *Faults
+	n      int // Messages written, for Faults.DropEvery
 }
 
 func (w *messageWriter) Write(b []byte) (n int, err error) {
-	w.out <- &Message{Id: w.id, Kind: w.kind, Body: string(b)}
+	if w.ready != nil {
+		<-w.ready
+	}
+	if w.count != nil {
+		w.count.Add(int64(len(b)))
+	}
+	time.Sleep(w.faults.WriteDelay)
+	if w.n++; w.faults.DropEvery > 0 && w.n%w.faults.DropEvery == 0 {
+		return len(b), nil
+	}
+	body := string(b)
+	if w.redact != nil {
+		body = w.redact.Replace(body)
+	}
+	m := newMessage(w.id, w.kind, body)
+	m.Label = w.label
+	w.out <- m
+	if len(w.parsers) > 0 {
+		for _, m := range w.progress(body) {
+			w.out <- m
+		}
+	}
 	return len(b), nil
 }
 
 // limiter returns a channel that wraps dest. Messages sent to the channel are
-// sent to dest. After msgLimit Messages have been passed on, a "kill" Message
-// is sent to the kill channel, and only "end" messages are passed.
-func limiter(kill chan<- *Message, dest chan<- *Message) chan<- *Message {
-	ch := make(chan *Message)
+// sent to dest. After limit Messages have been passed on, stop is called
+// with the reason "output-limit", such as by a Process' stop method, and
+// only "end" messages are passed. The returned done channel is closed
+// once the "end" Message has been passed on.
+func limiter(limit int, stop func(reason string, err error), dest chan<- *Message) (ch chan<- *Message, done <-chan struct{}) {
+	c := make(chan *Message)
+	passed := make(chan struct{})
 	go func() {
 		n := 0
-		for m := range ch {
+		for m := range c {
 			switch {
-			case n < msgLimit || m.Kind == "end":
+			case n < limit || m.Kind == "end":
 				dest <- m
 				if m.Kind == "end" {
+					close(passed)
 					return
 				}
-			case n == msgLimit:
+			case n == limit:
 				// Process produced too much output. Kill it.
-				kill <- &Message{Id: m.Id, Kind: "kill"}
+				go stop("output-limit", nil)
 			}
 			n++
 		}
 	}()
-	return ch
+	return c, passed
 }
 
-
-
 var uniq = make(chan int) // a source of numbers for naming temporary files
 
 func init() {
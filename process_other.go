@@ -0,0 +1,60 @@
+// Copyright 2012 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !unix
+
+package process
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+)
+
+func setUser(cmd *exec.Cmd, u *User) error {
+	return errors.New("running as another user is not supported on this system")
+}
+
+var errNoPause = errors.New("pause and resume are not supported on this system")
+
+func (p *Process) pause() error {
+	return errNoPause
+}
+
+func (p *Process) resume() error {
+	return errNoPause
+}
+
+// signalName returns "", as processes are not terminated by signals here.
+func signalName(s *os.ProcessState) string {
+	return ""
+}
+
+// signalByName accepts only "SIGKILL", the one signal that can be sent to
+// a process here.
+func signalByName(name string) (os.Signal, bool) {
+	if name == "SIGKILL" {
+		return os.Kill, true
+	}
+	return nil, false
+}
+
+// nameOfSignal returns "SIGKILL" for os.Kill.
+func nameOfSignal(sig os.Signal) (string, bool) {
+	if sig == os.Kill {
+		return "SIGKILL", true
+	}
+	return "", false
+}
+
+// isExecFormat reports false, as the format of programs is not checked
+// here.
+func isExecFormat(err error) bool {
+	return false
+}
+
+// maxRSS returns 0, as memory use is not reported here.
+func maxRSS(s *os.ProcessState) int64 {
+	return 0
+}
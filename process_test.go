@@ -1,41 +1,385 @@
 package process
 
 import (
+	"context"
+	"io"
+	"runtime"
+	"strconv"
+	"strings"
 	"testing"
-	"io/ioutil"
-	"os"
+	"time"
 )
 
 func TestBasic(t *testing.T) {
+	unixTools(t)
 	contents := `#!/bin/sh
 
 echo "hello there"
 echo "hello cat"
 `
-	confirmOutput := func (contents string, output []string) {
-		fname := "barbar"
-		args := []string{"./barbar"}
+	confirmOutput := func(contents string, output []string) {
 		o := make(chan *Message)
-		ioutil.WriteFile(fname, []byte(contents), 0777)	
-		defer os.Remove(fname)
+		got := make(chan string)
 		go func() {
-			i := 0 
+			var stdout []string
 			for j := range o {
-				if i < len(output) {
-				if j.Body != output[i] {
-					t.Errorf("%s != %s", j.Body, output[i])
-				} else {
-					t.Errorf("%s == %s", j.Body, output[i])
-				}
-				i++
-				} else {
-					t.Errorf("too much output: \"%s\"", j.Body)
+				if j.Kind == "end" {
+					break
 				}
+				stdout = append(stdout, j.Body)
 			}
+			got <- strings.Join(stdout, "")
 		}()
-		p := StartProcess("", args, o)
+		p := RunScript(nil, "", contents, o)
 		t.Log(p)
 		<-p.Done
+		if g, w := <-got, strings.Join(output, ""); g != w {
+			t.Errorf("%q != %q", g, w)
+		}
+	}
+	confirmOutput(contents, []string{"hello there\n", "hello cat\n"})
+}
+
+// unixTools skips tests that run Unix programs such as sh and sleep.
+func unixTools(t testing.TB) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs Unix tools")
+	}
+}
+
+// collect gathers the Messages sent on out, up to and including "end".
+func collect(out <-chan *Message) <-chan []*Message {
+	c := make(chan []*Message, 1)
+	go func() {
+		var ms []*Message
+		for m := range out {
+			ms = append(ms, m)
+			if m.Kind == "end" {
+				break
+			}
+		}
+		c <- ms
+	}()
+	return c
+}
+
+// drain releases Messages on out until "end".
+func drain(out <-chan *Message) {
+	for m := range out {
+		end := m.Kind == "end"
+		m.Release()
+		if end {
+			return
+		}
+	}
+}
+
+func BenchmarkStart(b *testing.B) {
+	unixTools(b)
+	for i := 0; i < b.N; i++ {
+		o := make(chan *Message)
+		go drain(o)
+		p := StartProcess(nil, []string{"true"}, o)
+		<-p.Done
 	}
-	confirmOutput(contents, []string{"hello there\nhello cat"})
+}
+
+func BenchmarkOutput(b *testing.B) {
+	unixTools(b)
+	const size = 1 << 20
+	b.SetBytes(size)
+	for i := 0; i < b.N; i++ {
+		o := make(chan *Message)
+		go drain(o)
+		p := StartProcess(nil, []string{"head", "-c", strconv.Itoa(size), "/dev/zero"}, o)
+		<-p.Done
+	}
+}
+
+func BenchmarkConcurrent(b *testing.B) {
+	unixTools(b)
+	const n = 50
+	for i := 0; i < b.N; i++ {
+		ps := make([]*Process, n)
+		for j := range ps {
+			o := make(chan *Message)
+			go drain(o)
+			ps[j] = StartProcess(nil, []string{"sh", "-c", "echo hello"}, o)
+		}
+		for _, p := range ps {
+			<-p.Done
+		}
+	}
+}
+
+func TestStartAsync(t *testing.T) {
+	unixTools(t)
+	o := make(chan *Message)
+	c := collect(o)
+	p := StartAsync(nil, []string{"echo", "hi"}, o)
+	<-p.Done
+	ms := <-c
+	if len(ms) != 3 {
+		t.Fatalf("got %d Messages, want started, stdout and end: %+v", len(ms), ms)
+	}
+	if f := strings.Fields(ms[0].Body); ms[0].Kind != "started" || len(f) != 2 {
+		t.Errorf("first Message = %+v, want started with pid and time", ms[0])
+	}
+	if ms[1].Kind != "stdout" || ms[1].Body != "hi\n" || ms[2].Kind != "end" {
+		t.Errorf("got %+v %+v, want stdout \"hi\\n\" then end", ms[1], ms[2])
+	}
+
+	o = make(chan *Message)
+	c = collect(o)
+	p = StartAsync(nil, []string{"./does-not-exist"}, o)
+	p.Kill()
+	if ms := <-c; len(ms) != 1 || ms[0].Kind != "end" || ms[0].Body == "" {
+		t.Errorf("got %+v, want a single end Message with an error", ms)
+	}
+}
+
+func TestPauseResume(t *testing.T) {
+	unixTools(t)
+	o := make(chan *Message)
+	p := StartProcess(nil, []string{"sh", "-c", "echo a; sleep 0.1; echo b"}, o)
+	if m := <-o; m.Body != "a\n" {
+		t.Fatalf("got %+v, want stdout a", m)
+	}
+	if err := p.Handle(&Message{Kind: "pause"}); err != nil {
+		t.Fatal(err)
+	}
+	paused := time.Now()
+	time.Sleep(300 * time.Millisecond)
+	if err := p.Handle(&Message{Kind: "resume"}); err != nil {
+		t.Fatal(err)
+	}
+	if m := <-o; m.Body != "b\n" {
+		t.Fatalf("got %+v, want stdout b", m)
+	}
+	if d := time.Since(paused); d < 300*time.Millisecond {
+		t.Errorf("output resumed after %v, want at least the pause duration", d)
+	}
+	<-o
+	<-p.Done
+}
+
+func TestErr(t *testing.T) {
+	unixTools(t)
+	o := make(chan *Message)
+	c := collect(o)
+	p := StartProcess(nil, []string{"sh", "-c", "exit 3"}, o)
+	<-c
+	for err := range p.Err() {
+		t.Errorf("Err() = %v for a program exiting non-zero", err)
+	}
+
+	o = make(chan *Message)
+	c = collect(o)
+	p = StartAsync(nil, []string{"./does-not-exist"}, o)
+	<-c
+	if err := <-p.Err(); err == nil {
+		t.Errorf("Err() = nil for a program that could not be started")
+	}
+}
+
+func TestStdin(t *testing.T) {
+	unixTools(t)
+	o := make(chan *Message)
+	c := collect(o)
+	p := StartProcessSpec(&ProcessSpec{Args: []string{"cat"}, Stdin: true}, o)
+	for _, m := range []*Message{
+		{Kind: "stdin", Body: "hello "},
+		{Kind: "stdin", Body: "cat\n"},
+		{Kind: "stdin-eof"},
+	} {
+		if err := p.Handle(m); err != nil {
+			t.Fatalf("Handle(%+v): %v", m, err)
+		}
+	}
+	<-p.Done
+	var got string
+	for _, m := range <-c {
+		if m.Kind == "stdout" {
+			got += m.Body
+		}
+	}
+	if got != "hello cat\n" {
+		t.Errorf("stdout = %q, want %q", got, "hello cat\n")
+	}
+
+	o = make(chan *Message)
+	go drain(o)
+	p = StartProcess(nil, []string{"cat"}, o)
+	if err := p.Handle(&Message{Kind: "stdin", Body: "x"}); err == nil {
+		t.Errorf("stdin accepted by a Process started without Stdin")
+	}
+	<-p.Done
+}
+
+func TestStartProcessContext(t *testing.T) {
+	unixTools(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	o := make(chan *Message)
+	c := collect(o)
+	p := StartProcessContext(ctx, nil, []string{"sleep", "10"}, o)
+	cancel()
+	<-p.Done
+	if ms := <-c; len(ms) != 1 || ms[0].Kind != "end" || ms[0].Body != "canceled" {
+		t.Errorf("got %+v, want end Message with reason canceled", ms)
+	}
+
+	o = make(chan *Message)
+	c = collect(o)
+	if p := StartProcessContext(ctx, nil, []string{"true"}, o); p != nil {
+		t.Errorf("started a Process with a canceled context")
+	}
+	if ms := <-c; len(ms) != 1 || ms[0].Body != "canceled" {
+		t.Errorf("got %+v, want end Message with reason canceled", ms)
+	}
+}
+
+func TestEndStatus(t *testing.T) {
+	unixTools(t)
+	end := func(args []string, stop func(*Process)) *Message {
+		o := make(chan *Message)
+		c := collect(o)
+		p := StartProcess(nil, args, o)
+		if p != nil && stop != nil {
+			stop(p)
+		}
+		ms := <-c
+		return ms[len(ms)-1]
+	}
+	for _, tt := range []struct {
+		m        *Message
+		code     int
+		sig, why string
+	}{
+		{end([]string{"sh", "-c", "exit 3"}, nil), 3, "", "exited"},
+		{end([]string{"sh", "-c", "kill -TERM $$"}, nil), -1, "SIGTERM", "signaled"},
+		{end([]string{"sleep", "10"}, (*Process).Kill), -1, "SIGKILL", "killed"},
+		{end([]string{"./does-not-exist"}, nil), 0, "", "start-failed"},
+	} {
+		if tt.m.ExitCode != tt.code || tt.m.Signal != tt.sig || tt.m.Reason != tt.why {
+			t.Errorf("got %+v, want ExitCode %d, Signal %q, Reason %q", tt.m, tt.code, tt.sig, tt.why)
+		}
+	}
+}
+
+func TestGracefulStop(t *testing.T) {
+	unixTools(t)
+	stop := func(script string) []*Message {
+		o := make(chan *Message)
+		c := collect(o)
+		p := StartProcessSpec(&ProcessSpec{
+			Args:        []string{"sh", "-c", "echo ready; " + script + "; while :; do sleep 0.05; done"},
+			GracePeriod: 500 * time.Millisecond,
+		}, o)
+		time.Sleep(100 * time.Millisecond)
+		p.Kill()
+		return <-c
+	}
+	ms := stop(`trap 'echo bye; exit 0' TERM`)
+	var out string
+	for _, m := range ms {
+		if m.Kind == "stdout" {
+			out += m.Body
+		}
+	}
+	if m := ms[len(ms)-1]; m.Termination != "graceful" || m.ExitCode != 0 || out != "ready\nbye\n" {
+		t.Errorf("got %+v after %q; want a graceful exit after saying bye", m, out)
+	}
+	ms = stop(`trap '' TERM`)
+	if m := ms[len(ms)-1]; m.Termination != "forced" || m.Signal != "SIGKILL" || m.Reason != "killed" {
+		t.Errorf("got %+v, want the program killed after the grace period", m)
+	}
+}
+
+func TestSignal(t *testing.T) {
+	unixTools(t)
+	o := make(chan *Message)
+	p := StartProcess(nil, []string{"sh", "-c", "trap 'echo hup' HUP; echo ready; while :; do sleep 0.05; done"}, o)
+	if m := <-o; m.Body != "ready\n" {
+		t.Fatalf("got %+v, want ready", m)
+	}
+	if err := p.Handle(&Message{Kind: "signal", Body: "SIGHUP"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Handle(&Message{Kind: "signal", Body: "SIGNOPE"}); err == nil {
+		t.Errorf("unknown signal accepted")
+	}
+	// The signal reaches the whole process group, so sh may report that
+	// it killed sleep before the trap runs.
+	defer time.AfterFunc(5*time.Second, p.Kill).Stop()
+	for m := range o {
+		if m.Kind == "end" {
+			t.Fatalf("got %+v, want the HUP trap to run", m)
+		}
+		if m.Kind == "stdout" && m.Body == "hup\n" {
+			break
+		}
+	}
+	go drain(o)
+	p.Kill()
+}
+
+func TestStats(t *testing.T) {
+	unixTools(t)
+	o := make(chan *Message)
+	c := collect(o)
+	p := StartProcessSpec(&ProcessSpec{
+		Args:  []string{"sh", "-c", "cat; echo oops >&2"},
+		Stdin: true,
+		Setup: []Hook{{Args: []string{"echo", "not counted"}}},
+	}, o)
+	io.WriteString(p, "hello\n")
+	p.CloseStdin()
+	<-p.Done
+	ms := <-c
+	want := Stats{StdinBytes: 6, StdoutBytes: 6, StderrBytes: 5}
+	got := ms[len(ms)-1].Stats
+	if got == nil || got.Elapsed <= 0 {
+		t.Fatalf("got %+v, want Stats with the run time", got)
+	}
+	got.Elapsed, got.CPU, got.MaxRSS = 0, 0, 0
+	if *got != want {
+		t.Errorf("Stats = %+v, want %+v", *got, want)
+	}
+}
+
+func TestLimiter(t *testing.T) {
+	stopped := make(chan string, 1)
+	dest := make(chan *Message, 10)
+	ch, done := limiter(5, func(reason string, err error) { stopped <- reason }, dest)
+	for i := 0; i < 10; i++ {
+		ch <- &Message{Kind: "stdout"}
+	}
+	ch <- &Message{Kind: "end"}
+	<-done
+	if why := <-stopped; why != "output-limit" {
+		t.Errorf("stopped for %q, want output-limit", why)
+	}
+	if n := len(dest); n != 6 {
+		t.Errorf("passed %d Messages, want 5 and the end", n)
+	}
+
+	unixTools(t)
+	o := make(chan *Message)
+	c := collect(o)
+	StartProcessSpec(&ProcessSpec{Args: []string{"yes"}, OutputLimit: 5}, o)
+	ms := <-c
+	if m := ms[len(ms)-1]; len(ms) > 6 || m.Reason != "output-limit" || m.Signal != "SIGKILL" {
+		t.Errorf("got %d Messages ending in %+v, want at most 5 and an output-limit end", len(ms)-1, m)
+	}
+
+	// Failing to start closes out with the limiter in the way too.
+	o = make(chan *Message)
+	closed := make(chan struct{})
+	go func() {
+		for range o {
+		}
+		close(closed)
+	}()
+	StartProcessSpec(&ProcessSpec{Args: []string{"./does-not-exist"}, OutputLimit: 5}, o)
+	<-closed
 }
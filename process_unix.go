@@ -0,0 +1,114 @@
+// Copyright 2012 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build unix
+
+package process
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"syscall"
+)
+
+// setUser arranges for cmd to run as u.
+func setUser(cmd *exec.Cmd, u *User) error {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = new(syscall.SysProcAttr)
+	}
+	cmd.SysProcAttr.Credential = &syscall.Credential{
+		Uid:    u.Uid,
+		Gid:    u.Gid,
+		Groups: u.Groups,
+	}
+	return nil
+}
+
+func (p *Process) pause() error {
+	return p.run.Signal(syscall.SIGSTOP)
+}
+
+func (p *Process) resume() error {
+	return p.run.Signal(syscall.SIGCONT)
+}
+
+var signalNames = map[syscall.Signal]string{
+	syscall.SIGABRT:  "SIGABRT",
+	syscall.SIGALRM:  "SIGALRM",
+	syscall.SIGBUS:   "SIGBUS",
+	syscall.SIGCONT:  "SIGCONT",
+	syscall.SIGFPE:   "SIGFPE",
+	syscall.SIGHUP:   "SIGHUP",
+	syscall.SIGILL:   "SIGILL",
+	syscall.SIGINT:   "SIGINT",
+	syscall.SIGKILL:  "SIGKILL",
+	syscall.SIGPIPE:  "SIGPIPE",
+	syscall.SIGQUIT:  "SIGQUIT",
+	syscall.SIGSEGV:  "SIGSEGV",
+	syscall.SIGSTOP:  "SIGSTOP",
+	syscall.SIGSYS:   "SIGSYS",
+	syscall.SIGTERM:  "SIGTERM",
+	syscall.SIGTSTP:  "SIGTSTP",
+	syscall.SIGTRAP:  "SIGTRAP",
+	syscall.SIGUSR1:  "SIGUSR1",
+	syscall.SIGUSR2:  "SIGUSR2",
+	syscall.SIGWINCH: "SIGWINCH",
+	syscall.SIGXCPU:  "SIGXCPU",
+	syscall.SIGXFSZ:  "SIGXFSZ",
+}
+
+// signalName returns the name of the signal that terminated the process
+// described by s, or "" if it exited normally.
+func signalName(s *os.ProcessState) string {
+	ws, ok := s.Sys().(syscall.WaitStatus)
+	if !ok || !ws.Signaled() {
+		return ""
+	}
+	if name, ok := signalNames[ws.Signal()]; ok {
+		return name
+	}
+	return "signal " + strconv.Itoa(int(ws.Signal()))
+}
+
+// signalByName returns the signal called name, such as "SIGHUP".
+func signalByName(name string) (os.Signal, bool) {
+	for sig, n := range signalNames {
+		if n == name {
+			return sig, true
+		}
+	}
+	return nil, false
+}
+
+// nameOfSignal returns the name of sig, such as "SIGHUP".
+func nameOfSignal(sig os.Signal) (string, bool) {
+	s, ok := sig.(syscall.Signal)
+	if !ok {
+		return "", false
+	}
+	name, ok := signalNames[s]
+	return name, ok
+}
+
+// isExecFormat reports whether err says a file is not in a format the
+// system can execute.
+func isExecFormat(err error) bool {
+	return errors.Is(err, syscall.ENOEXEC)
+}
+
+// maxRSS returns the most memory the process described by s had
+// resident, in bytes.
+func maxRSS(s *os.ProcessState) int64 {
+	ru, ok := s.SysUsage().(*syscall.Rusage)
+	if !ok {
+		return 0
+	}
+	if runtime.GOOS == "darwin" || runtime.GOOS == "ios" {
+		return int64(ru.Maxrss) // already in bytes
+	}
+	return int64(ru.Maxrss) * 1024
+}
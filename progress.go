@@ -0,0 +1,113 @@
+// Copyright 2012 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package process
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Progress describes how far a program has got with one item of work, as
+// recognized in a line of its output by a ProgressParser.
+type Progress struct {
+	Item  string  // what is being worked on, such as a package; may be ""
+	Done  float64 // how much of it is done
+	Total float64 // how much there is in all, or 0 if unknown
+	Unit  string  // unit of Done and Total: "B" for bytes, "%", or "" for a count
+}
+
+// A ProgressParser recognizes a line of output reporting progress. The
+// line has no trailing newline or carriage return.
+type ProgressParser func(line string) (Progress, bool)
+
+// maxProgressLine bounds the partial line held back while waiting for
+// the rest of it.
+const maxProgressLine = 4096
+
+var goTestLine = regexp.MustCompile(`^(?:ok|FAIL|\?) *\t(\S+)\t`)
+
+// GoTestProgress recognizes the line "go test" prints as it finishes
+// each package, reporting the package as done.
+func GoTestProgress(line string) (Progress, bool) {
+	m := goTestLine.FindStringSubmatch(line)
+	if m == nil {
+		return Progress{}, false
+	}
+	return Progress{Item: m[1], Done: 1, Total: 1}, true
+}
+
+var pipLine = regexp.MustCompile(`([\d.]+)/([\d.]+) ([kMG]?B) `)
+
+// PipProgress recognizes pip's download progress bar.
+func PipProgress(line string) (Progress, bool) {
+	m := pipLine.FindStringSubmatch(line)
+	if m == nil {
+		return Progress{}, false
+	}
+	return byteProgress("", m[1], m[3], m[2], m[3])
+}
+
+var aptLine = regexp.MustCompile(`^Progress: \[ *(\d+)%\]`)
+
+// AptProgress recognizes the overall progress line of apt and apt-get.
+func AptProgress(line string) (Progress, bool) {
+	m := aptLine.FindStringSubmatch(line)
+	if m == nil {
+		return Progress{}, false
+	}
+	n, _ := strconv.ParseFloat(m[1], 64)
+	return Progress{Done: n, Total: 100, Unit: "%"}, true
+}
+
+var dockerLine = regexp.MustCompile(`^([0-9a-f]{12}): (?:Downloading|Extracting) +\[[=> ]*\] +([\d.]+)([kMG]?B)/([\d.]+)([kMG]?B)`)
+
+// DockerPullProgress recognizes the per-layer progress lines of "docker
+// pull", reporting the layer id as the item.
+func DockerPullProgress(line string) (Progress, bool) {
+	m := dockerLine.FindStringSubmatch(line)
+	if m == nil {
+		return Progress{}, false
+	}
+	return byteProgress(m[1], m[2], m[3], m[4], m[5])
+}
+
+// byteProgress returns the Progress of item given its sizes done and in
+// total, each as a number and a unit such as "MB".
+func byteProgress(item, done, doneUnit, total, totalUnit string) (Progress, bool) {
+	d, err1 := strconv.ParseFloat(done, 64)
+	t, err2 := strconv.ParseFloat(total, 64)
+	if err1 != nil || err2 != nil {
+		return Progress{}, false
+	}
+	return Progress{Item: item, Done: d * unitSize[doneUnit], Total: t * unitSize[totalUnit], Unit: "B"}, true
+}
+
+var unitSize = map[string]float64{"B": 1, "kB": 1e3, "MB": 1e6, "GB": 1e9}
+
+// progress returns a "progress" Message for each line in b, as completed
+// by the partial line left over from the previous write, that one of
+// w.parsers recognizes.
+func (w *messageWriter) progress(b string) []*Message {
+	b = w.partial + b
+	i := strings.LastIndexAny(b, "\r\n")
+	w.partial = b[i+1:]
+	if len(w.partial) > maxProgressLine {
+		w.partial = ""
+	}
+	var ms []*Message
+	for _, line := range strings.FieldsFunc(b[:i+1], func(r rune) bool { return r == '\r' || r == '\n' }) {
+		for _, parse := range w.parsers {
+			if pr, ok := parse(line); ok {
+				m := newMessage(w.id, "progress", line)
+				m.Label = w.label
+				m.Progress = &pr
+				ms = append(ms, m)
+				break
+			}
+		}
+	}
+	return ms
+}
@@ -0,0 +1,56 @@
+package process
+
+import "testing"
+
+func TestProgressParsers(t *testing.T) {
+	for _, tt := range []struct {
+		parse ProgressParser
+		line  string
+		want  Progress
+	}{
+		{GoTestProgress, "ok  \texample.com/a\t0.012s", Progress{Item: "example.com/a", Done: 1, Total: 1}},
+		{GoTestProgress, "FAIL\texample.com/b\t0.300s", Progress{Item: "example.com/b", Done: 1, Total: 1}},
+		{GoTestProgress, "?   \texample.com/c\t[no test files]", Progress{Item: "example.com/c", Done: 1, Total: 1}},
+		{PipProgress, "   ━━━━━━━━━━━━━━━━━━━━ 1.5/3.0 MB 5.0 MB/s eta 0:00:01", Progress{Done: 1.5e6, Total: 3e6, Unit: "B"}},
+		{AptProgress, "Progress: [ 45%] [####.....]", Progress{Done: 45, Total: 100, Unit: "%"}},
+		{DockerPullProgress, "a3ed95caeb02: Downloading [==>       ]  512kB/2.31MB", Progress{Item: "a3ed95caeb02", Done: 512e3, Total: 2.31e6, Unit: "B"}},
+	} {
+		if got, ok := tt.parse(tt.line); !ok || got != tt.want {
+			t.Errorf("parsing %q = %+v, %v; want %+v", tt.line, got, ok, tt.want)
+		}
+	}
+	for _, line := range []string{"FAIL", "--- FAIL: TestX (0.00s)", "Downloading pkg-1.0.whl (3.0 MB)"} {
+		for _, parse := range []ProgressParser{GoTestProgress, PipProgress, AptProgress, DockerPullProgress} {
+			if got, ok := parse(line); ok {
+				t.Errorf("parsing %q = %+v, want no progress", line, got)
+			}
+		}
+	}
+}
+
+func TestProgressMessages(t *testing.T) {
+	unixTools(t)
+	o := make(chan *Message)
+	c := collect(o)
+	p := StartProcessSpec(&ProcessSpec{
+		Args:     []string{"sh", "-c", `printf 'Progress: [ 10%%]\rProg'; sleep 0.1; printf 'ress: [ 20%%]\rdone\n'`},
+		Progress: []ProgressParser{AptProgress},
+	}, o)
+	<-p.Done
+	var raw string
+	var done []float64
+	for _, m := range <-c {
+		switch m.Kind {
+		case "stdout":
+			raw += m.Body
+		case "progress":
+			done = append(done, m.Progress.Done)
+		}
+	}
+	if raw != "Progress: [ 10%]\rProgress: [ 20%]\rdone\n" {
+		t.Errorf("stdout = %q, want the raw output", raw)
+	}
+	if len(done) != 2 || done[0] != 10 || done[1] != 20 {
+		t.Errorf("progress = %v, want [10 20]", done)
+	}
+}
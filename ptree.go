@@ -0,0 +1,34 @@
+// Copyright 2012 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package process
+
+import (
+	"errors"
+	"time"
+)
+
+// A TreeNode describes one process of a running program's process tree.
+type TreeNode struct {
+	Pid      int
+	Command  string        // command line, with arguments separated by spaces
+	CPU      time.Duration // processor time used so far
+	RSS      int64         // resident memory in bytes
+	Children []*TreeNode
+}
+
+// Tree returns the tree of processes the running program has started,
+// rooted at the program itself. Processes whose parent has exited are
+// included, as children of the root, while they remain in the program's
+// process group. It is a snapshot; processes come and go as it is taken.
+func (p *Process) Tree() (*TreeNode, error) {
+	if err := p.running(); err != nil {
+		return nil, err
+	}
+	r, ok := p.run.(*execRun)
+	if !ok {
+		return nil, errors.New("process trees are not supported with a Runner")
+	}
+	return processTree(r.cmd.Process.Pid)
+}
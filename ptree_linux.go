@@ -0,0 +1,157 @@
+// Copyright 2012 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package process
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// clockTick is the unit of the times in /proc/<pid>/stat. It is
+// USER_HZ, which is 100 on every architecture Linux supports.
+const clockTick = time.Second / 100
+
+// procStat is what processTree needs from /proc/<pid>/stat.
+type procStat struct {
+	pid, ppid, pgid int
+	comm            string
+	state           byte
+	cpu             time.Duration
+	rss             int64
+}
+
+// readProcStat reads the stat file of process pid.
+func readProcStat(pid int) (*procStat, error) {
+	b, err := ioutil.ReadFile("/proc/" + strconv.Itoa(pid) + "/stat")
+	if err != nil {
+		return nil, err
+	}
+	// The command name is in parentheses and may itself contain spaces
+	// and parentheses, so split the rest after the last ")".
+	s := string(b)
+	i, j := strings.IndexByte(s, '('), strings.LastIndexByte(s, ')')
+	if i < 0 || j < i {
+		return nil, errors.New("malformed stat file for process " + strconv.Itoa(pid))
+	}
+	f := strings.Fields(s[j+1:])
+	if len(f) < 22 {
+		return nil, errors.New("malformed stat file for process " + strconv.Itoa(pid))
+	}
+	// f[0] is field 3 of proc(5): state.
+	num := func(k int) int64 {
+		n, _ := strconv.ParseInt(f[k-3], 10, 64)
+		return n
+	}
+	return &procStat{
+		pid:   pid,
+		ppid:  int(num(4)),
+		pgid:  int(num(5)),
+		comm:  s[i+1 : j],
+		state: f[0][0],
+		cpu:   time.Duration(num(14)+num(15)) * clockTick,
+		rss:   num(24) * int64(os.Getpagesize()),
+	}, nil
+}
+
+// procStats reads the stat files of all processes.
+func procStats() ([]*procStat, error) {
+	d, err := os.Open("/proc")
+	if err != nil {
+		return nil, err
+	}
+	names, err := d.Readdirnames(-1)
+	d.Close()
+	if err != nil {
+		return nil, err
+	}
+	var ss []*procStat
+	for _, name := range names {
+		n, err := strconv.Atoi(name)
+		if err != nil {
+			continue
+		}
+		s, err := readProcStat(n)
+		if err != nil {
+			continue // it has exited
+		}
+		ss = append(ss, s)
+	}
+	return ss, nil
+}
+
+// groupSize returns the number of live processes in process group pgid.
+func groupSize(pgid int) int {
+	ss, _ := procStats()
+	n := 0
+	for _, s := range ss {
+		if s.pgid == pgid && s.state != 'Z' {
+			n++
+		}
+	}
+	return n
+}
+
+// processTree walks /proc for the processes descended from pid or in
+// its process group.
+func processTree(pid int) (*TreeNode, error) {
+	ss, err := procStats()
+	if err != nil {
+		return nil, err
+	}
+	var root *procStat
+	var group []*procStat
+	children := make(map[int][]*procStat)
+	for _, s := range ss {
+		if s.pid == pid {
+			root = s
+			continue
+		}
+		children[s.ppid] = append(children[s.ppid], s)
+		if s.pgid == pid {
+			group = append(group, s)
+		}
+	}
+	if root == nil {
+		return nil, errors.New("process " + strconv.Itoa(pid) + " not found")
+	}
+	seen := make(map[int]bool)
+	var node func(s *procStat) *TreeNode
+	node = func(s *procStat) *TreeNode {
+		seen[s.pid] = true
+		n := &TreeNode{Pid: s.pid, Command: command(s), CPU: s.cpu, RSS: s.rss}
+		for _, c := range children[s.pid] {
+			n.Children = append(n.Children, node(c))
+		}
+		return n
+	}
+	t := node(root)
+	inGroup := make(map[int]bool)
+	for _, s := range group {
+		inGroup[s.pid] = true
+	}
+	for _, s := range group {
+		// Orphans in the group, and what they started, go under the root.
+		if !seen[s.pid] && !inGroup[s.ppid] {
+			t.Children = append(t.Children, node(s))
+		}
+	}
+	return t, nil
+}
+
+// command returns the command line of the process s describes, or its
+// command name in parentheses if it has none, as for a zombie.
+func command(s *procStat) string {
+	b, _ := ioutil.ReadFile("/proc/" + strconv.Itoa(s.pid) + "/cmdline")
+	b = bytes.TrimRight(b, "\x00")
+	if len(b) == 0 {
+		return "(" + s.comm + ")"
+	}
+	return string(bytes.Replace(b, []byte{0}, []byte{' '}, -1))
+}
@@ -0,0 +1,41 @@
+package process
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestTree(t *testing.T) {
+	o := make(chan *Message)
+	go drain(o)
+	p := StartProcessSpec(&ProcessSpec{
+		Args: []string{"sh", "-c", "(sleep 10 &); sleep 10 & wait"},
+	}, o)
+	defer p.Kill()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		tree, err := p.Tree()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var sleeps int
+		for _, c := range tree.Children {
+			if c.Command == "sleep 10" && c.RSS > 0 {
+				sleeps++
+			}
+		}
+		// One sleep is the shell's child, the other an orphan in its
+		// process group.
+		if sleeps == 2 {
+			if strconv.Itoa(tree.Pid) != p.run.ID() || tree.Command != "sh -c (sleep 10 &); sleep 10 & wait" {
+				t.Errorf("root is %+v, want the shell", tree)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("got %+v, want two sleeps under the shell", tree)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
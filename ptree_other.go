@@ -0,0 +1,18 @@
+// Copyright 2012 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !linux
+
+package process
+
+import "errors"
+
+func processTree(pid int) (*TreeNode, error) {
+	return nil, errors.New("process trees are not supported on this system")
+}
+
+// groupSize returns 1, as process group members are not counted here.
+func groupSize(pgid int) int {
+	return 1
+}
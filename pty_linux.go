@@ -0,0 +1,98 @@
+// Copyright 2012 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package process
+
+import (
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"syscall"
+	"unsafe"
+)
+
+// openPTY allocates a pseudo-terminal and returns its master and slave.
+func openPTY() (master, slave *os.File, err error) {
+	master, err = os.OpenFile("/dev/ptmx", os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	var n, unlock uint32
+	if err := ioctl(master, syscall.TIOCSPTLCK, unsafe.Pointer(&unlock)); err != nil {
+		master.Close()
+		return nil, nil, err
+	}
+	if err := ioctl(master, syscall.TIOCGPTN, unsafe.Pointer(&n)); err != nil {
+		master.Close()
+		return nil, nil, err
+	}
+	slave, err = os.OpenFile("/dev/pts/"+strconv.Itoa(int(n)), os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		master.Close()
+		return nil, nil, err
+	}
+	return master, slave, nil
+}
+
+func ioctl(f *os.File, req uintptr, arg unsafe.Pointer) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), req, uintptr(arg))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// usePTY arranges for cmd to run as a session leader with a new
+// pseudo-terminal as its controlling terminal and standard input, output
+// and error. The returned function must be called once cmd has started;
+// it copies the terminal's output to the Process as "stdout" Messages.
+func (p *Process) usePTY(cmd *exec.Cmd) (started func(), err error) {
+	master, slave, err := openPTY()
+	if err != nil {
+		return nil, errors.New("allocating pty: " + err.Error())
+	}
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = slave, slave, slave
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = new(syscall.SysProcAttr)
+	}
+	cmd.SysProcAttr.Setsid = true
+	cmd.SysProcAttr.Setctty = true
+	cmd.SysProcAttr.Ctty = 0 // the child's stdin
+	p.onRelease(func() {
+		master.Close()
+		slave.Close()
+	})
+	p.pty = master
+	p.ptyDone = make(chan struct{})
+	p.stdin = ptyInput{master}
+	return func() {
+		slave.Close()
+		go func() {
+			// Reading the master fails with EIO once every process
+			// holding the slave has gone; that is the terminal's EOF.
+			io.Copy(p.writer("stdout", "", p.started), master)
+			close(p.ptyDone)
+		}()
+	}, nil
+}
+
+// setSize sets the window size of the terminal whose master is f.
+func setSize(f *os.File, rows, cols int) error {
+	ws := struct{ row, col, xpixel, ypixel uint16 }{uint16(rows), uint16(cols), 0, 0}
+	return ioctl(f, syscall.TIOCSWINSZ, unsafe.Pointer(&ws))
+}
+
+// ptyInput is the stdin of a Process running on a pseudo-terminal.
+type ptyInput struct {
+	*os.File
+}
+
+// Close sends the terminal's end-of-file character rather than closing
+// the master, which would hang up the session.
+func (in ptyInput) Close() error {
+	_, err := in.Write([]byte{4}) // ^D
+	return err
+}
@@ -0,0 +1,71 @@
+package process
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPTY(t *testing.T) {
+	o := make(chan *Message)
+	c := collect(o)
+	p := StartProcessSpec(&ProcessSpec{
+		Args: []string{"sh", "-c", "test -t 0 && test -t 1 && test -t 2 && echo tty"},
+		PTY:  true,
+	}, o)
+	if p == nil {
+		t.Fatalf("StartProcessSpec failed: %+v", <-c)
+	}
+	<-p.Done
+	ms := <-c
+	var out string
+	for _, m := range ms {
+		if m.Kind == "stdout" {
+			out += m.Body
+		}
+	}
+	if out != "tty\r\n" {
+		t.Errorf("output = %q, want %q", out, "tty\r\n")
+	}
+
+	o = make(chan *Message)
+	c = collect(o)
+	p = StartProcessSpec(&ProcessSpec{Args: []string{"cat"}, PTY: true}, o)
+	p.Handle(&Message{Kind: "stdin", Body: "hello\n"})
+	p.Handle(&Message{Kind: "stdin-eof"})
+	<-p.Done
+	out = ""
+	for _, m := range <-c {
+		if m.Kind == "stdout" {
+			out += m.Body
+		}
+	}
+	// The terminal echoes the input, then cat prints it.
+	if strings.Count(out, "hello\r\n") != 2 {
+		t.Errorf("output = %q, want hello echoed and printed", out)
+	}
+}
+
+func TestResize(t *testing.T) {
+	o := make(chan *Message)
+	c := collect(o)
+	p := StartProcessSpec(&ProcessSpec{
+		Args:  []string{"sh", "-c", "read x; stty size"},
+		PTY:   true,
+		Stdin: true,
+	}, o)
+	if err := p.Handle(&Message{Kind: "resize", Body: "33 99"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Handle(&Message{Kind: "resize", Body: "33"}); err == nil {
+		t.Errorf("resize without columns accepted")
+	}
+	p.Handle(&Message{Kind: "stdin", Body: "\n"})
+	<-p.Done
+	var out string
+	for _, m := range <-c {
+		out += m.Body
+	}
+	if !strings.Contains(out, "33 99\r\n") {
+		t.Errorf("output = %q, want stty to report 33 99", out)
+	}
+}
@@ -0,0 +1,23 @@
+// Copyright 2012 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !linux
+
+package process
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+)
+
+var errNoPTY = errors.New("pty mode is not supported on this system")
+
+func (p *Process) usePTY(cmd *exec.Cmd) (started func(), err error) {
+	return nil, errNoPTY
+}
+
+func setSize(f *os.File, rows, cols int) error {
+	return errNoPTY
+}
@@ -0,0 +1,109 @@
+// Copyright 2012 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package process
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"strconv"
+	"time"
+)
+
+// A Runner starts programs somewhere other than on the server itself,
+// such as in a container or on a remote host. Everything else about a
+// Process, including its Messages, hooks, locks and secrets, works the
+// same whatever runs the program; hooks still run on the server.
+type Runner interface {
+	// Start starts the program spec describes, writing its standard
+	// output and error to stdout and stderr and, if stdin is not nil,
+	// reading its standard input from stdin. The program's environment
+	// is env, which holds spec.Env, the variables for spec.Locale and
+	// spec.TZ, and any secrets, added to whatever the Runner provides;
+	// the server's own environment is not passed on. A Runner must
+	// report an error for spec settings it cannot honor, such as Limits
+	// or User.
+	Start(spec *ProcessSpec, env []string, stdin io.Reader, stdout, stderr io.Writer) (Run, error)
+}
+
+// A Run is a program started by a Runner.
+type Run interface {
+	// ID identifies the program, such as by its process id, and is
+	// reported in the "started" Message.
+	ID() string
+
+	// Wait waits for the program to exit and for its output to be
+	// written, and reports how it ended. An error reports a failure of
+	// the Runner rather than of the program.
+	Wait() (ExitStatus, error)
+
+	// Signal sends sig to the program.
+	Signal(sig os.Signal) error
+
+	// Kill stops the program and any processes it started at once.
+	Kill() error
+}
+
+// ExitStatus describes how a program ended.
+type ExitStatus struct {
+	Code   int           // exit status, or -1 if it did not exit normally
+	Signal string        // name of the signal that terminated it, such as "SIGKILL"
+	CPU    time.Duration // processor time it used, if known
+	MaxRSS int64         // most memory it had resident in bytes, if known
+
+	// Leaked is how many processes the program started were still
+	// running when it exited, and so were killed.
+	Leaked int
+
+	// Reason, if not empty, is reported as the "end" Message's Reason
+	// unless the Process was stopped.
+	Reason string
+}
+
+// err returns the error a program ending with s reports, or nil if it
+// succeeded.
+func (s ExitStatus) err() error {
+	switch {
+	case s.Signal != "":
+		return errors.New("signal: " + s.Signal)
+	case s.Code != 0:
+		return errors.New("exit status " + strconv.Itoa(s.Code))
+	}
+	return nil
+}
+
+// startRunner starts the program of spec with spec.Runner.
+func (p *Process) startRunner(spec *ProcessSpec) error {
+	if spec.PTY || spec.Cgroup != nil || spec.Seccomp != nil || spec.Isolation != nil || spec.FDSampleInterval > 0 {
+		return errors.New("PTY, Cgroup, Seccomp, Isolation and FDSampleInterval are not supported with a Runner")
+	}
+	var stdin io.Reader
+	if spec.Stdin {
+		r, w := io.Pipe()
+		// Fail writes once the program has ended, even if the Runner
+		// stopped reading before that.
+		p.onRelease(func() { r.Close() })
+		stdin, p.stdin = r, w
+	}
+	run, err := spec.Runner.Start(spec, specEnv(spec, p.secrets), stdin,
+		p.writer("stdout", "", p.started), p.writer("stderr", "", p.started))
+	if err != nil {
+		return err
+	}
+	p.run = run
+	return nil
+}
+
+// runName returns a new random name for a program started by a Runner,
+// such as "process-8c3f1e2a9b0d4c57".
+func runName() (string, error) {
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	return "process-" + hex.EncodeToString(b[:]), nil
+}
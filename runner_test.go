@@ -0,0 +1,95 @@
+package process
+
+import (
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+)
+
+// echoRunner runs a pretend program that writes its environment and then
+// copies its standard input to standard output until it is killed or its
+// input ends.
+type echoRunner struct{}
+
+type echoRun struct {
+	done   chan struct{}
+	killed chan struct{}
+	status ExitStatus
+}
+
+func (echoRunner) Start(spec *ProcessSpec, env []string, stdin io.Reader, stdout, stderr io.Writer) (Run, error) {
+	if spec.Args[0] != "echo" {
+		return nil, errors.New("no such program")
+	}
+	r := &echoRun{done: make(chan struct{}), killed: make(chan struct{})}
+	go func() {
+		defer close(r.done)
+		io.WriteString(stdout, strings.Join(env, " ")+"\n")
+		if stdin == nil {
+			stdin = strings.NewReader("")
+		}
+		copied := make(chan struct{})
+		go func() {
+			io.Copy(stdout, stdin)
+			close(copied)
+		}()
+		select {
+		case <-copied:
+			r.status = ExitStatus{Code: 3}
+		case <-r.killed:
+			r.status = ExitStatus{Code: -1, Signal: "SIGKILL", Reason: "preempted"}
+		}
+	}()
+	return r, nil
+}
+
+func (r *echoRun) ID() string                 { return "echo-1" }
+func (r *echoRun) Signal(sig os.Signal) error { return errors.New("not supported") }
+func (r *echoRun) Kill() error                { close(r.killed); return nil }
+func (r *echoRun) Wait() (ExitStatus, error)  { <-r.done; return r.status, nil }
+
+func TestRunner(t *testing.T) {
+	os.Setenv("PROCESS_TEST_SERVER", "x")
+	defer os.Unsetenv("PROCESS_TEST_SERVER")
+	o := make(chan *Message)
+	c := collect(o)
+	p := StartProcessSpec(&ProcessSpec{
+		Args:   []string{"echo"},
+		Env:    []string{"A=1"},
+		Stdin:  true,
+		Runner: echoRunner{},
+	}, o)
+	io.WriteString(p, "hello\n")
+	p.CloseStdin()
+	<-p.Done
+	ms := <-c
+	if ms[0].Body != "A=1\n" || ms[1].Body != "hello\n" {
+		t.Errorf("got %+v, %+v; want the spec's environment and the input", ms[0], ms[1])
+	}
+	if m := ms[len(ms)-1]; m.Body != "exit status 3" || m.ExitCode != 3 || m.Reason != "exited" {
+		t.Errorf("got %+v, want exit status 3", m)
+	}
+
+	o = make(chan *Message)
+	c = collect(o)
+	p = StartProcessSpec(&ProcessSpec{Args: []string{"echo"}, Stdin: true, Runner: echoRunner{}}, o)
+	p.Kill()
+	if _, err := p.Write([]byte("late")); err == nil {
+		t.Errorf("Write after the program ended succeeded")
+	}
+	ms = <-c
+	if m := ms[len(ms)-1]; m.Signal != "SIGKILL" || m.Reason != "killed" {
+		t.Errorf("got %+v, want the run killed", m)
+	}
+
+	o = make(chan *Message)
+	c = collect(o)
+	if p := StartProcessSpec(&ProcessSpec{Args: []string{"cat"}, Runner: echoRunner{}}, o); p != nil {
+		t.Errorf("started a program the Runner does not have")
+	}
+	if ms := <-c; ms[0].Body != "no such program" || ms[0].Reason != "start-failed" {
+		t.Errorf("got %+v, want the Runner's error", ms[0])
+	}
+}
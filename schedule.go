@@ -0,0 +1,225 @@
+// Copyright 2012 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package process
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Scheduled describes a run waiting in a Scheduler.
+type Scheduled struct {
+	Id   string
+	Spec *ProcessSpec
+	Cron string    // the cron expression, or "" for a single run
+	Next time.Time // when it is next due
+	Runs int       // how many times it has been started
+}
+
+// A Scheduler starts ProcessSpecs at given times, once or repeatedly.
+// The Messages of every run of a schedule carry the schedule's id as
+// their Id, and the run's number, counting from 1, as their Label, or as
+// a prefix to it as for a Group. Each run ends with its own "end"
+// Message. A Scheduler is safe for concurrent use.
+//
+// Runs that have started are killed by Cancel and Stop.
+type Scheduler struct {
+	mu      sync.Mutex
+	entries map[string]*entry
+}
+
+type entry struct {
+	Scheduled
+	cron  *Cron
+	out   chan<- *Message
+	timer *time.Timer
+
+	// Under the Scheduler's mu:
+	canceled bool
+	runs     int               // runs started and not yet ended
+	procs    map[*Process]bool // those of them that are running
+}
+
+// NewScheduler returns an empty Scheduler.
+func NewScheduler() *Scheduler {
+	return &Scheduler{entries: make(map[string]*entry)}
+}
+
+// At schedules spec to run once at t, sending its Messages on out, and
+// returns the schedule's id.
+func (s *Scheduler) At(t time.Time, spec *ProcessSpec, out chan<- *Message) string {
+	return s.add(&entry{Scheduled: Scheduled{Spec: spec, Next: t}, out: out})
+}
+
+// Cron schedules spec to run at every minute the cron expression expr
+// matches, as described for ParseCron, sending the Messages of each run
+// on out. It returns the schedule's id, or an error if expr is malformed
+// or matches no time in the next five years.
+func (s *Scheduler) Cron(expr string, spec *ProcessSpec, out chan<- *Message) (string, error) {
+	c, err := ParseCron(expr)
+	if err != nil {
+		return "", err
+	}
+	e := &entry{Scheduled: Scheduled{Spec: spec, Cron: expr}, cron: c, out: out}
+	e.Next = c.Next(time.Now())
+	if e.Next.IsZero() {
+		return "", errors.New("cron expression " + strconv.Quote(expr) + " never matches")
+	}
+	return s.add(e), nil
+}
+
+func (s *Scheduler) add(e *entry) string {
+	e.Id = newID()
+	e.procs = make(map[*Process]bool)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries[e.Id] = e
+	s.arm(e)
+	return e.Id
+}
+
+// arm sets e's timer for its next run or, if it has none, removes e once
+// its runs have ended. s.mu must be held.
+func (s *Scheduler) arm(e *entry) {
+	if e.Next.IsZero() {
+		if e.runs == 0 {
+			delete(s.entries, e.Id)
+		}
+		return
+	}
+	e.timer = time.AfterFunc(time.Until(e.Next), func() { s.fire(e) })
+}
+
+// fire starts a run of e and arms it for the next.
+func (s *Scheduler) fire(e *entry) {
+	s.mu.Lock()
+	if s.entries[e.Id] != e {
+		s.mu.Unlock()
+		return // canceled
+	}
+	e.Runs++
+	e.runs++
+	n := e.Runs
+	if e.cron != nil {
+		e.Next = e.cron.Next(time.Now())
+	} else {
+		e.Next = time.Time{}
+	}
+	s.arm(e)
+	s.mu.Unlock()
+	go s.run(e, n)
+}
+
+// run runs e's spec, relaying its Messages as run n of the schedule.
+func (s *Scheduler) run(e *entry, n int) {
+	ch := make(chan *Message)
+	started := make(chan *Process, 1)
+	go func() {
+		p := StartProcessSpec(e.Spec, ch)
+		if p != nil {
+			s.mu.Lock()
+			e.procs[p] = true
+			kill := e.canceled
+			s.mu.Unlock()
+			if kill {
+				go p.Kill()
+			}
+		}
+		started <- p
+	}()
+	label := strconv.Itoa(n)
+	for m := range ch {
+		m.Id = e.Id
+		if m.Label == "" {
+			m.Label = label
+		} else {
+			m.Label = label + "/" + m.Label
+		}
+		e.out <- m
+		if m.Kind == "end" {
+			break
+		}
+	}
+	p := <-started
+	if p != nil {
+		<-p.Done
+	}
+	s.mu.Lock()
+	delete(e.procs, p)
+	e.runs--
+	if e.Next.IsZero() && e.runs == 0 && s.entries[e.Id] == e {
+		delete(s.entries, e.Id)
+	}
+	s.mu.Unlock()
+}
+
+// List returns the waiting schedules, soonest first.
+func (s *Scheduler) List() []Scheduled {
+	s.mu.Lock()
+	list := make([]Scheduled, 0, len(s.entries))
+	for _, e := range s.entries {
+		if !e.Next.IsZero() {
+			list = append(list, e.Scheduled)
+		}
+	}
+	s.mu.Unlock()
+	sort.Slice(list, func(i, j int) bool { return list[i].Next.Before(list[j].Next) })
+	return list
+}
+
+// Cancel removes the schedule with the given id and kills its runs that
+// have started, reporting whether it was waiting or running.
+func (s *Scheduler) Cancel(id string) bool {
+	s.mu.Lock()
+	e, ok := s.entries[id]
+	var procs []*Process
+	if ok {
+		procs = s.cancel(e)
+	}
+	s.mu.Unlock()
+	killAll(procs)
+	return ok
+}
+
+// Stop removes every schedule and kills the runs that have started.
+func (s *Scheduler) Stop() {
+	s.mu.Lock()
+	var procs []*Process
+	for _, e := range s.entries {
+		procs = append(procs, s.cancel(e)...)
+	}
+	s.mu.Unlock()
+	killAll(procs)
+}
+
+// cancel removes e and returns its running Processes. s.mu must be held.
+func (s *Scheduler) cancel(e *entry) []*Process {
+	e.canceled = true
+	if e.timer != nil {
+		e.timer.Stop()
+	}
+	delete(s.entries, e.Id)
+	var procs []*Process
+	for p := range e.procs {
+		procs = append(procs, p)
+	}
+	return procs
+}
+
+// killAll kills procs and waits for them to exit.
+func killAll(procs []*Process) {
+	var wg sync.WaitGroup
+	for _, p := range procs {
+		wg.Add(1)
+		go func(p *Process) {
+			p.Kill()
+			wg.Done()
+		}(p)
+	}
+	wg.Wait()
+}
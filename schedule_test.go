@@ -0,0 +1,68 @@
+package process
+
+import (
+	"testing"
+	"time"
+)
+
+func TestScheduler(t *testing.T) {
+	unixTools(t)
+	s := NewScheduler()
+	o := make(chan *Message)
+	c := collect(o)
+	id := s.At(time.Now().Add(50*time.Millisecond), &ProcessSpec{Args: []string{"echo", "hi"}}, o)
+	cid, err := s.Cron("@yearly", &ProcessSpec{Args: []string{"true"}}, o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l := s.List(); len(l) != 2 || l[0].Id != id || l[1].Id != cid || l[1].Next.IsZero() {
+		t.Errorf("List() = %+v, want the single run first", l)
+	}
+	ms := <-c
+	if len(ms) != 2 || ms[0].Id != id || ms[0].Label != "1" || ms[0].Body != "hi\n" || ms[1].Id != id {
+		t.Errorf("got %+v, want the run's Messages tagged with the schedule", ms)
+	}
+	if l := s.List(); len(l) != 1 || l[0].Id != cid {
+		t.Errorf("List() = %+v, want the single run gone", l)
+	}
+	if !s.Cancel(cid) || s.Cancel(cid) || len(s.List()) != 0 {
+		t.Errorf("Cancel did not remove the schedule once")
+	}
+	if _, err := s.Cron("bad", nil, o); err == nil {
+		t.Errorf("Cron accepted a bad expression")
+	}
+}
+
+func TestSchedulerNeverMatches(t *testing.T) {
+	s := NewScheduler()
+	if id, err := s.Cron("0 0 31 2 *", &ProcessSpec{Args: []string{"true"}}, nil); err == nil {
+		t.Errorf("Cron accepted an expression that never matches, as %s", id)
+	}
+	if l := s.List(); len(l) != 0 {
+		t.Errorf("List() = %+v, want nothing scheduled", l)
+	}
+}
+
+func TestSchedulerKillsRuns(t *testing.T) {
+	unixTools(t)
+	for _, stop := range []func(s *Scheduler, id string){
+		func(s *Scheduler, id string) {
+			if !s.Cancel(id) {
+				t.Errorf("Cancel(%s) found no running schedule", id)
+			}
+		},
+		func(s *Scheduler, id string) { s.Stop() },
+	} {
+		s := NewScheduler()
+		o := make(chan *Message)
+		c := collect(o)
+		id := s.At(time.Now(), &ProcessSpec{Args: []string{"sleep", "10"}}, o)
+		time.Sleep(200 * time.Millisecond)
+		start := time.Now()
+		stop(s, id)
+		ms := <-c
+		if m := ms[len(ms)-1]; m.Reason != "killed" || time.Since(start) > 5*time.Second {
+			t.Errorf("got %+v, want the run killed", m)
+		}
+	}
+}
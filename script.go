@@ -0,0 +1,61 @@
+// Copyright 2012 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package process
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// RunScript is like StartProcess, but runs script, written to a new file
+// in dir that only the server's user may access and that is removed once
+// the Process ends. If interpreter is not empty the file is run with it,
+// as in "sh script-1234"; otherwise the script must start with a #! line.
+// If dir is nil the file is written to the server's temporary directory,
+// and the program runs in the server's own working directory.
+func RunScript(dir *string, interpreter, script string, out chan<- *Message) *Process {
+	p := newProcess(out)
+	spec := newSpec(dir, nil)
+	name, err := writeScript(spec.Dir, script)
+	if err != nil {
+		close(p.started)
+		p.end(err)
+		close(out)
+		return nil
+	}
+	p.onRelease(func() { os.Remove(name) })
+	spec.Args = []string{name}
+	if interpreter != "" {
+		spec.Args = []string{interpreter, name}
+	}
+	if p = p.launch(context.Background(), spec); p == nil {
+		// start fails before it has anything to release for some errors.
+		os.Remove(name)
+	}
+	return p
+}
+
+// writeScript writes script to a new executable file in dir, or in the
+// temporary directory if dir is empty, and returns its absolute path.
+func writeScript(dir, script string) (string, error) {
+	f, err := ioutil.TempFile(dir, "script-")
+	if err != nil {
+		return "", err
+	}
+	_, err = f.WriteString(script)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(f.Name(), 0700)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return filepath.Abs(f.Name())
+}
@@ -0,0 +1,21 @@
+package process
+
+import (
+	"io/ioutil"
+	"testing"
+)
+
+func TestRunScript(t *testing.T) {
+	unixTools(t)
+	dir := t.TempDir()
+	o := make(chan *Message)
+	c := collect(o)
+	p := RunScript(&dir, "sh", "pwd\n", o)
+	<-p.Done
+	if ms := <-c; len(ms) != 2 || ms[0].Body != dir+"\n" {
+		t.Fatalf("got %+v, want the script run in %s", ms[0], dir)
+	}
+	if files, _ := ioutil.ReadDir(dir); len(files) != 0 {
+		t.Errorf("%s holds %d files after the script ended, want none", dir, len(files))
+	}
+}
@@ -0,0 +1,21 @@
+// Copyright 2012 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package process
+
+// A SeccompProfile is a seccomp-bpf filter: a classic BPF program run by
+// the kernel on each system call the program makes, as for the
+// SECCOMP_SET_MODE_FILTER operation of seccomp(2). Operators may build
+// their own, for instance by exporting one from libseccomp, or use
+// DefaultSeccompProfile. Seccomp is only supported on Linux on amd64 and
+// arm64.
+type SeccompProfile []BPFInstruction
+
+// A BPFInstruction is one instruction of a classic BPF program, laid out
+// like the kernel's struct sock_filter.
+type BPFInstruction struct {
+	Code   uint16
+	Jt, Jf uint8
+	K      uint32
+}
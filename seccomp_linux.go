@@ -0,0 +1,111 @@
+// Copyright 2012 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build linux && (amd64 || arm64)
+
+package process
+
+import (
+	"encoding/binary"
+	"errors"
+	"syscall"
+	"unsafe"
+)
+
+// Classic BPF opcodes and seccomp constants from linux/filter.h and
+// linux/seccomp.h.
+const (
+	bpfLoad    = 0x20 // BPF_LD | BPF_W | BPF_ABS
+	bpfJumpEq  = 0x15 // BPF_JMP | BPF_JEQ | BPF_K
+	bpfJumpGe  = 0x35 // BPF_JMP | BPF_JGE | BPF_K
+	bpfAnd     = 0x54 // BPF_ALU | BPF_AND | BPF_K
+	bpfReturn  = 0x06 // BPF_RET | BPF_K
+	retAllow   = 0x7fff0000
+	retErrno   = 0x00050000
+	retKill    = 0x80000000 // SECCOMP_RET_KILL_PROCESS
+	offNr      = 0          // offsets in struct seccomp_data
+	offArch    = 4
+	offArg0    = 16
+	offArg1    = 24
+	x32Bit     = 0x40000000
+	afPacket   = 17
+	sockRaw    = 3
+	sockTypeMk = 0xf
+	cloneNewMk = 0x7e020000 // the CLONE_NEW* flags clone takes
+
+	prSetNoNewPrivs = 38
+	seccompSetMode  = 1 // SECCOMP_SET_MODE_FILTER
+	seccompTsync    = 1 // SECCOMP_FILTER_FLAG_TSYNC
+)
+
+// DefaultSeccompProfile returns a profile that makes the system calls
+// listed in deniedSyscalls, the creation of namespaces with clone, and
+// the creation of packet and raw sockets fail with EPERM. clone3, whose
+// flags a filter cannot see, fails with ENOSYS, so that programs fall
+// back to clone. Processes for another architecture are killed.
+func DefaultSeccompProfile() (SeccompProfile, error) {
+	deny := BPFInstruction{Code: bpfReturn, K: retErrno | uint32(syscall.EPERM)}
+	p := SeccompProfile{
+		{Code: bpfLoad, K: offArch},
+		{Code: bpfJumpEq, Jt: 1, K: auditArch},
+		{Code: bpfReturn, K: retKill},
+		{Code: bpfLoad, K: offNr},
+		{Code: bpfJumpGe, Jf: 1, K: x32Bit},
+		deny,
+	}
+	for _, nr := range deniedSyscalls {
+		p = append(p, BPFInstruction{Code: bpfJumpEq, Jf: 1, K: nr}, deny)
+	}
+	return append(p,
+		BPFInstruction{Code: bpfJumpEq, Jf: 5, K: sysClone},
+		BPFInstruction{Code: bpfLoad, K: offArg0},
+		BPFInstruction{Code: bpfAnd, K: cloneNewMk},
+		BPFInstruction{Code: bpfJumpEq, Jf: 1, K: 0},
+		BPFInstruction{Code: bpfReturn, K: retAllow},
+		deny,
+		BPFInstruction{Code: bpfJumpEq, Jf: 1, K: sysClone3},
+		BPFInstruction{Code: bpfReturn, K: retErrno | uint32(syscall.ENOSYS)},
+		BPFInstruction{Code: bpfJumpEq, Jf: 6, K: sysSocket},
+		BPFInstruction{Code: bpfLoad, K: offArg0},
+		BPFInstruction{Code: bpfJumpEq, Jt: 3, K: afPacket},
+		BPFInstruction{Code: bpfLoad, K: offArg1},
+		BPFInstruction{Code: bpfAnd, K: sockTypeMk},
+		BPFInstruction{Code: bpfJumpEq, Jf: 1, K: sockRaw},
+		deny,
+		BPFInstruction{Code: bpfReturn, K: retAllow},
+	), nil
+}
+
+// useSeccomp arranges for the program h starts to run under profile.
+func useSeccomp(h *helper, profile SeccompProfile) error {
+	b := make([]byte, 8*len(profile))
+	for i, in := range profile {
+		binary.LittleEndian.PutUint16(b[8*i:], in.Code)
+		b[8*i+2], b[8*i+3] = in.Jt, in.Jf
+		binary.LittleEndian.PutUint32(b[8*i+4:], in.K)
+	}
+	h.Seccomp = b
+	return nil
+}
+
+// installSeccomp installs b, a filter laid out by useSeccomp, for the
+// calling thread and the program it executes.
+func installSeccomp(b []byte) error {
+	if len(b) == 0 || len(b)%8 != 0 {
+		return errors.New("bad profile")
+	}
+	// No new privileges is a per-thread setting, so it and the filter
+	// must be set from the thread that executes the program.
+	if _, _, errno := syscall.RawSyscall(syscall.SYS_PRCTL, prSetNoNewPrivs, 1, 0); errno != 0 {
+		return errno
+	}
+	prog := struct {
+		len    uint16
+		filter unsafe.Pointer
+	}{uint16(len(b) / 8), unsafe.Pointer(&b[0])}
+	if _, _, errno := syscall.RawSyscall(sysSeccomp, seccompSetMode, seccompTsync, uintptr(unsafe.Pointer(&prog))); errno != 0 {
+		return errno
+	}
+	return nil
+}
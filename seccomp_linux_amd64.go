@@ -0,0 +1,42 @@
+// Copyright 2012 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package process
+
+const (
+	auditArch  = 0xc000003e // AUDIT_ARCH_X86_64
+	sysSeccomp = 317
+	sysSocket  = 41
+	sysClone   = 56
+	sysClone3  = 435
+)
+
+// deniedSyscalls are the system calls DefaultSeccompProfile refuses:
+// those for debugging other processes, changing mounts, namespaces and
+// keyrings, loading kernel code, and rebooting.
+var deniedSyscalls = []uint32{
+	101, // ptrace
+	310, // process_vm_readv
+	311, // process_vm_writev
+	165, // mount
+	166, // umount2
+	155, // pivot_root
+	304, // open_by_handle_at
+	272, // unshare
+	308, // setns
+	248, // add_key
+	249, // request_key
+	250, // keyctl
+	175, // init_module
+	313, // finit_module
+	176, // delete_module
+	246, // kexec_load
+	320, // kexec_file_load
+	321, // bpf
+	298, // perf_event_open
+	323, // userfaultfd
+	167, // swapon
+	168, // swapoff
+	169, // reboot
+}
@@ -0,0 +1,42 @@
+// Copyright 2012 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package process
+
+const (
+	auditArch  = 0xc00000b7 // AUDIT_ARCH_AARCH64
+	sysSeccomp = 277
+	sysSocket  = 198
+	sysClone   = 220
+	sysClone3  = 435
+)
+
+// deniedSyscalls are the system calls DefaultSeccompProfile refuses:
+// those for debugging other processes, changing mounts, namespaces and
+// keyrings, loading kernel code, and rebooting.
+var deniedSyscalls = []uint32{
+	117, // ptrace
+	270, // process_vm_readv
+	271, // process_vm_writev
+	40,  // mount
+	39,  // umount2
+	41,  // pivot_root
+	265, // open_by_handle_at
+	97,  // unshare
+	268, // setns
+	217, // add_key
+	218, // request_key
+	219, // keyctl
+	105, // init_module
+	273, // finit_module
+	106, // delete_module
+	104, // kexec_load
+	294, // kexec_file_load
+	280, // bpf
+	241, // perf_event_open
+	282, // userfaultfd
+	224, // swapon
+	225, // swapoff
+	142, // reboot
+}
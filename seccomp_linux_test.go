@@ -0,0 +1,60 @@
+//go:build amd64 || arm64
+
+package process
+
+import (
+	"os"
+	"syscall"
+	"testing"
+)
+
+func TestSeccompHelper(t *testing.T) {
+	if os.Getenv("PROCESS_TEST_SECCOMP") == "" {
+		t.Skip("run by TestSeccomp")
+	}
+	os.Stdout.WriteString("unshare: " + errString(syscall.Unshare(0)) + "\n")
+	_, err := syscall.Socket(syscall.AF_PACKET, syscall.SOCK_RAW, 0)
+	os.Stdout.WriteString("packet socket: " + errString(err) + "\n")
+	// Without the filter, these fail with EINVAL rather than run.
+	_, _, errno := syscall.RawSyscall(syscall.SYS_CLONE, syscall.CLONE_NEWUSER|syscall.CLONE_FS, 0, 0)
+	os.Stdout.WriteString("clone: " + errno.Error() + "\n")
+	_, _, errno = syscall.RawSyscall(sysClone3, 0, 0, 0)
+	os.Stdout.WriteString("clone3: " + errno.Error() + "\n")
+	os.Exit(0)
+}
+
+func errString(err error) string {
+	if err == nil {
+		return "ok"
+	}
+	return err.Error()
+}
+
+func TestSeccomp(t *testing.T) {
+	profile, err := DefaultSeccompProfile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	o := make(chan *Message)
+	c := collect(o)
+	StartProcessSpec(&ProcessSpec{
+		Args:    []string{os.Args[0], "-test.run=^TestSeccompHelper$"},
+		Env:     []string{"PROCESS_TEST_SECCOMP=1"},
+		Seccomp: profile,
+	}, o)
+	var out string
+	for _, m := range <-c {
+		out += m.Body
+	}
+	if want := "unshare: operation not permitted\npacket socket: operation not permitted\n" +
+		"clone: operation not permitted\nclone3: function not implemented\n"; out != want {
+		t.Errorf("output = %q, want %q", out, want)
+	}
+
+	o = make(chan *Message)
+	c = collect(o)
+	StartProcessSpec(&ProcessSpec{Args: []string{"sh", "-c", "echo $(echo hi)"}, Seccomp: profile}, o)
+	if ms := <-c; ms[0].Body != "hi\n" || ms[1].ExitCode != 0 {
+		t.Errorf("got %+v, want sh to fork and run under the profile", ms)
+	}
+}
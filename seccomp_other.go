@@ -0,0 +1,24 @@
+// Copyright 2012 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !linux || !(amd64 || arm64)
+
+package process
+
+import "errors"
+
+var errNoSeccomp = errors.New("seccomp is not supported on this system")
+
+// DefaultSeccompProfile returns an error, as seccomp is not supported.
+func DefaultSeccompProfile() (SeccompProfile, error) {
+	return nil, errNoSeccomp
+}
+
+func useSeccomp(h *helper, profile SeccompProfile) error {
+	return errNoSeccomp
+}
+
+func installSeccomp(b []byte) error {
+	return errNoSeccomp
+}
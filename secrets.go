@@ -0,0 +1,161 @@
+// Copyright 2012 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package process
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// A SecretProvider looks up secret values by name.
+type SecretProvider interface {
+	Secret(name string) (string, error)
+}
+
+// EnvSecrets provides secrets from the server's own environment: the
+// secret called name is the value of the variable Prefix+name.
+type EnvSecrets struct {
+	Prefix string
+}
+
+func (s EnvSecrets) Secret(name string) (string, error) {
+	v, ok := os.LookupEnv(s.Prefix + name)
+	if !ok {
+		return "", errors.New(s.Prefix + name + " is not set")
+	}
+	return v, nil
+}
+
+// FileSecrets provides secrets stored one per file in Dir, as mounted by
+// Docker and Kubernetes. A trailing newline is not part of the secret.
+type FileSecrets struct {
+	Dir string
+}
+
+func (s FileSecrets) Secret(name string) (string, error) {
+	if name == "" || strings.ContainsAny(name, `/\`) || name == "." || name == ".." {
+		return "", errors.New("bad secret name " + name)
+	}
+	b, err := ioutil.ReadFile(filepath.Join(s.Dir, name))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(string(b), "\n"), nil
+}
+
+// VaultSecrets provides secrets stored as the keys of one secret in a
+// HashiCorp Vault KV version 2 secrets engine.
+type VaultSecrets struct {
+	Addr   string       // Vault server URL, such as "https://vault:8200"
+	Token  string       // Vault token
+	Mount  string       // mount point of the KV engine; "" means "secret"
+	Path   string       // path of the secret within the engine
+	Client *http.Client // nil means http.DefaultClient
+}
+
+func (s VaultSecrets) Secret(name string) (string, error) {
+	mount := s.Mount
+	if mount == "" {
+		mount = "secret"
+	}
+	req, err := http.NewRequest("GET", strings.TrimSuffix(s.Addr, "/")+"/v1/"+mount+"/data/"+s.Path, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-Vault-Token", s.Token)
+	c := s.Client
+	if c == nil {
+		c = http.DefaultClient
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", errors.New("vault: " + resp.Status)
+	}
+	var v struct {
+		Data struct {
+			Data map[string]string
+		}
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&v); err != nil {
+		return "", err
+	}
+	secret, ok := v.Data.Data[name]
+	if !ok {
+		return "", errors.New("vault: no key " + name + " in " + url.PathEscape(s.Path))
+	}
+	return secret, nil
+}
+
+// A CredentialMinter issues credentials that are only valid for a single
+// run, such as short-lived cloud tokens, so that a value leaked by one
+// run is of little use afterwards.
+type CredentialMinter interface {
+	// Mint issues credentials for the Process with the given id and
+	// returns them as environment variables, along with a function that
+	// revokes them.
+	Mint(id string) (env map[string]string, revoke func() error, err error)
+}
+
+// fetchSecrets looks up the secrets named in spec and mints its
+// credentials, arranging for the credentials to be revoked when the
+// Process ends. It prepares the environment entries for both and the
+// Replacer redacting their values.
+func (p *Process) fetchSecrets(spec *ProcessSpec) error {
+	if len(spec.Secrets) == 0 && len(spec.Credentials) == 0 {
+		return nil
+	}
+	if len(spec.Secrets) > 0 && spec.SecretProvider == nil {
+		return errors.New("secrets requested without a SecretProvider")
+	}
+	var env, values []string
+	add := func(k, v string) {
+		env = append(env, k+"="+v)
+		if v != "" {
+			values = append(values, v)
+		}
+	}
+	for k, name := range spec.Secrets {
+		v, err := spec.SecretProvider.Secret(name)
+		if err != nil {
+			return errors.New("secret " + name + ": " + err.Error())
+		}
+		add(k, v)
+	}
+	for _, m := range spec.Credentials {
+		creds, revoke, err := m.Mint(p.id)
+		if err != nil {
+			return errors.New("minting credentials: " + err.Error())
+		}
+		p.onRelease(func() {
+			if err := revoke(); err != nil {
+				p.fail(errors.New("revoking credentials: " + err.Error()))
+			}
+		})
+		for k, v := range creds {
+			add(k, v)
+		}
+	}
+	// Replace longer values first so that a secret containing another
+	// is not left partly visible.
+	sort.Slice(values, func(i, j int) bool { return len(values[i]) > len(values[j]) })
+	var pairs []string
+	for _, v := range values {
+		pairs = append(pairs, v, "[redacted]")
+	}
+	p.secrets = env
+	p.redact = strings.NewReplacer(pairs...)
+	return nil
+}
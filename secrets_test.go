@@ -0,0 +1,89 @@
+package process
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+func TestSecrets(t *testing.T) {
+	unixTools(t)
+	dir := t.TempDir()
+	ioutil.WriteFile(filepath.Join(dir, "token"), []byte("s3cr3t\n"), 0600)
+	o := make(chan *Message)
+	c := collect(o)
+	p := StartProcessSpec(&ProcessSpec{
+		Args:           []string{"sh", "-c", `echo "token is $TOKEN"`},
+		Secrets:        map[string]string{"TOKEN": "token"},
+		SecretProvider: FileSecrets{dir},
+	}, o)
+	<-p.Done
+	if ms := <-c; ms[0].Body != "token is [redacted]\n" {
+		t.Errorf("stdout = %q, want the secret redacted", ms[0].Body)
+	}
+
+	o = make(chan *Message)
+	c = collect(o)
+	p = StartProcessSpec(&ProcessSpec{
+		Args:           []string{"true"},
+		Secrets:        map[string]string{"TOKEN": "../token"},
+		SecretProvider: FileSecrets{dir},
+	}, o)
+	if ms := <-c; p != nil || ms[0].Kind != "end" || ms[0].Body == "" {
+		t.Errorf("got %v, %+v; want a start error for a bad secret name", p, ms)
+	}
+}
+
+func TestVaultSecrets(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/kv/data/app" || r.Header.Get("X-Vault-Token") != "t" {
+			http.Error(w, "denied", http.StatusForbidden)
+			return
+		}
+		w.Write([]byte(`{"data":{"data":{"db":"hunter2"},"metadata":{}}}`))
+	}))
+	defer ts.Close()
+	s := VaultSecrets{Addr: ts.URL, Token: "t", Mount: "kv", Path: "app"}
+	if v, err := s.Secret("db"); v != "hunter2" || err != nil {
+		t.Errorf("Secret(db) = %q, %v", v, err)
+	}
+	if _, err := s.Secret("other"); err == nil {
+		t.Errorf("Secret(other) succeeded")
+	}
+	s.Token = "wrong"
+	if _, err := s.Secret("db"); err == nil {
+		t.Errorf("Secret with a bad token succeeded")
+	}
+}
+
+type testMinter struct {
+	revoked chan string
+}
+
+func (m testMinter) Mint(id string) (map[string]string, func() error, error) {
+	tok := "tok-" + id
+	return map[string]string{"API_TOKEN": tok}, func() error {
+		m.revoked <- tok
+		return nil
+	}, nil
+}
+
+func TestCredentials(t *testing.T) {
+	unixTools(t)
+	m := testMinter{make(chan string, 1)}
+	o := make(chan *Message)
+	c := collect(o)
+	p := StartProcessSpec(&ProcessSpec{
+		Args:        []string{"sh", "-c", `echo "$API_TOKEN"`},
+		Credentials: []CredentialMinter{m},
+	}, o)
+	<-p.Done
+	if ms := <-c; ms[0].Body != "[redacted]\n" {
+		t.Errorf("stdout = %q, want the credential redacted", ms[0].Body)
+	}
+	if tok := <-m.revoked; tok != "tok-"+p.id {
+		t.Errorf("revoked %q, want the token minted for %s", tok, p.id)
+	}
+}
@@ -0,0 +1,287 @@
+// Copyright 2012 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package process
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// SSH is a Runner that runs programs on a remote host using the ssh
+// command-line client, which must be able to log in without prompting,
+// such as with a key. The host needs a POSIX shell.
+//
+// spec.Args is the command run on the host and spec.Dir, if set, its
+// working directory there. The environment is sent over the connection
+// rather than in the command line, so secrets do not show up in process
+// listings. spec.User and spec.Limits are not supported.
+type SSH struct {
+	Host    string   // host to run programs on, as [user@]hostname
+	Options []string // extra ssh arguments, such as []string{"-i", "key"}
+
+	// Command is the ssh client to run. If empty, it is "ssh".
+	Command string
+}
+
+// envScript is a shell command run with a working directory and the
+// program's arguments, which changes to the directory, exports the
+// environment it reads from stdin up to an empty line, and then runs the
+// program in its place. See envInput.
+const envScript = `cd -- "$1" || exit 126; shift; ` +
+	`while IFS= read -r v && [ -n "$v" ]; do export "$v"; done; exec "$@"`
+
+// sshScript is the remote command. It reports its pid on stderr before
+// running envScript.
+const sshScript = `echo $$ >&2; ` + envScript
+
+// envInput returns the input envScript reads env from.
+func envInput(env []string) ([]byte, error) {
+	var b bytes.Buffer
+	for _, kv := range env {
+		if strings.ContainsAny(kv, "\n\x00") {
+			return nil, errors.New("environment variable contains a newline: " + kv[:strings.IndexByte(kv+"=", '=')])
+		}
+		b.WriteString(kv + "\n")
+	}
+	b.WriteString("\n")
+	return b.Bytes(), nil
+}
+
+// writeInput writes vars and then, if stdin is not nil, copies it to w,
+// closing w when done.
+func writeInput(w io.WriteCloser, vars []byte, stdin io.Reader) {
+	if _, err := w.Write(vars); err == nil && stdin != nil {
+		io.Copy(w, stdin)
+	}
+	w.Close()
+}
+
+// Start starts the program of spec on s.Host.
+func (s SSH) Start(spec *ProcessSpec, env []string, stdin io.Reader, stdout, stderr io.Writer) (Run, error) {
+	if s.Host == "" {
+		return nil, errors.New("SSH.Host is empty")
+	}
+	if spec.User != nil || !spec.Limits.zero() {
+		return nil, errors.New("User and Limits are not supported by the SSH runner")
+	}
+	vars, err := envInput(env)
+	if err != nil {
+		return nil, err
+	}
+	dir := spec.Dir
+	if dir == "" {
+		dir = "."
+	}
+	name, err := runName()
+	if err != nil {
+		return nil, err
+	}
+	r := &sshRun{s: s, name: name, ready: make(chan struct{})}
+	r.cmd = s.command(append([]string{"sh", "-c", sshScript, "sh", dir}, spec.Args...))
+	r.cmd.Stdout = stdout
+	r.cmd.Stderr = &pidWriter{r: r, w: stderr}
+	w, err := r.cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := r.cmd.Start(); err != nil {
+		return nil, err
+	}
+	go writeInput(w, vars, stdin)
+	return r, nil
+}
+
+// command returns an *exec.Cmd running args on s.Host.
+func (s SSH) command(args []string) *exec.Cmd {
+	quoted := make([]string, len(args))
+	for i, a := range args {
+		quoted[i] = "'" + strings.Replace(a, "'", `'\''`, -1) + "'"
+	}
+	name := s.Command
+	if name == "" {
+		name = "ssh"
+	}
+	sshArgs := append(append([]string(nil), s.Options...), "-T", "--", s.Host, strings.Join(quoted, " "))
+	return exec.Command(name, sshArgs...)
+}
+
+// sshRun is a program running on a remote host.
+type sshRun struct {
+	s     SSH
+	name  string
+	cmd   *exec.Cmd
+	ready chan struct{} // closed once pid is known
+	pid   int
+
+	mu     sync.Mutex
+	sent   string // the last signal sent to the program, if any
+	failed bool   // ssh reported a connection or authentication failure
+}
+
+// ID returns a name for the program, as its remote pid is not known
+// until it has started.
+func (r *sshRun) ID() string {
+	return r.name
+}
+
+// Wait waits for the ssh client to exit. The client exits with the
+// program's exit status, or 255 if the connection failed or the program
+// was terminated by a signal. Status 255 is only taken for a failure of
+// ssh if the remote program never started or ssh reported a connection
+// or authentication failure on stderr.
+func (r *sshRun) Wait() (ExitStatus, error) {
+	err := r.cmd.Wait()
+	if _, ok := err.(*exec.ExitError); !ok && err != nil {
+		return ExitStatus{}, err
+	}
+	code := r.cmd.ProcessState.ExitCode()
+	if code != 255 {
+		return ExitStatus{Code: code}, nil
+	}
+	r.mu.Lock()
+	sent, failed := r.sent, r.failed
+	r.mu.Unlock()
+	select {
+	case <-r.ready:
+	default:
+		failed = true
+	}
+	switch {
+	case failed:
+		return ExitStatus{Code: -1}, errors.New("ssh exited with status 255")
+	case sent != "":
+		return ExitStatus{Code: -1, Signal: sent}, nil
+	}
+	return ExitStatus{Code: code}, nil
+}
+
+// Signal sends sig to the remote program with kill(1).
+func (r *sshRun) Signal(sig os.Signal) error {
+	name, ok := nameOfSignal(sig)
+	if !ok {
+		return errors.New("unknown signal: " + sig.String())
+	}
+	return r.kill(name)
+}
+
+// Kill kills the remote program. Processes it started are left running.
+func (r *sshRun) Kill() error {
+	if err := r.kill("SIGKILL"); err != nil {
+		// The program may not have started yet; make sure the client
+		// at least stops waiting for it.
+		r.cmd.Process.Kill()
+		return err
+	}
+	return nil
+}
+
+func (r *sshRun) kill(signal string) error {
+	select {
+	case <-r.ready:
+	default:
+		return errors.New("remote program has not started")
+	}
+	// Note the signal first: the client may exit before kill returns.
+	r.mu.Lock()
+	prev := r.sent
+	r.sent = signal
+	r.mu.Unlock()
+	pid := strconv.Itoa(r.pid)
+	out, err := r.s.command([]string{"kill", "-s", strings.TrimPrefix(signal, "SIG"), pid}).CombinedOutput()
+	if err != nil {
+		r.mu.Lock()
+		r.sent = prev
+		r.mu.Unlock()
+		return errors.New("ssh kill: " + strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// sshFailures are the starts of lines ssh writes to stderr when it
+// cannot connect or log in, or loses the connection.
+var sshFailures = []string{
+	"ssh: ",
+	"Permission denied (",
+	"Host key verification failed",
+	"Connection closed by ",
+	"Connection reset by ",
+	"Connection timed out",
+	"kex_exchange_identification: ",
+	"client_loop: ",
+}
+
+// pidWriter passes the remote program's stderr on to w, after taking
+// the pid sshScript reports from its first line. It notes lines that
+// report a failure of ssh.
+type pidWriter struct {
+	r    *sshRun
+	w    io.Writer
+	mu   sync.Mutex
+	line []byte // the start of the line being written
+	done bool
+}
+
+func (pw *pidWriter) Write(b []byte) (int, error) {
+	pw.mu.Lock()
+	defer pw.mu.Unlock()
+	n := len(b)
+	if !pw.done {
+		i := bytes.IndexByte(b, '\n')
+		if i < 0 {
+			pw.line = append(pw.line, b...)
+			return n, nil
+		}
+		line := append(pw.line, b[:i]...)
+		pw.line = nil
+		b = b[i+1:]
+		pw.done = true
+		if pid, err := strconv.Atoi(string(line)); err == nil {
+			pw.r.pid = pid
+			close(pw.r.ready)
+		} else {
+			// Not the script's output, such as an error from ssh.
+			b = append(append(line, '\n'), b...)
+		}
+	}
+	pw.scan(b)
+	if len(b) > 0 {
+		if _, err := pw.w.Write(b); err != nil {
+			return 0, err
+		}
+	}
+	return n, nil
+}
+
+// scan looks for ssh's failures in the lines of b.
+func (pw *pidWriter) scan(b []byte) {
+	for len(b) > 0 {
+		i := bytes.IndexByte(b, '\n')
+		end := i
+		if i < 0 {
+			end = len(b)
+		}
+		if len(pw.line) < 64 {
+			pw.line = append(pw.line, b[:end]...)
+		}
+		if i < 0 {
+			return
+		}
+		for _, f := range sshFailures {
+			if bytes.HasPrefix(pw.line, []byte(f)) {
+				pw.r.mu.Lock()
+				pw.r.failed = true
+				pw.r.mu.Unlock()
+			}
+		}
+		pw.line = pw.line[:0]
+		b = b[i+1:]
+	}
+}
@@ -0,0 +1,124 @@
+package process
+
+import (
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// fakeSSH writes an ssh client to dir that runs the remote command
+// locally.
+func fakeSSH(t *testing.T, dir string) string {
+	script := `#!/bin/sh
+while [ "$1" != -- ]; do shift; done
+exec sh -c "$3"
+`
+	name := filepath.Join(dir, "ssh")
+	if err := ioutil.WriteFile(name, []byte(script), 0700); err != nil {
+		t.Fatal(err)
+	}
+	return name
+}
+
+func TestSSH(t *testing.T) {
+	unixTools(t)
+	dir := t.TempDir()
+	s := SSH{Host: "builder", Command: fakeSSH(t, dir)}
+	o := make(chan *Message)
+	c := collect(o)
+	p := StartProcessSpec(&ProcessSpec{
+		Args:   []string{"sh", "-c", `echo "$A"; pwd; cat; echo oops >&2; exit 3`},
+		Dir:    dir,
+		Env:    []string{"A=it's 1"},
+		Stdin:  true,
+		Runner: s,
+	}, o)
+	io.WriteString(p, "hello\n")
+	p.CloseStdin()
+	<-p.Done
+	var out, errOut string
+	ms := <-c
+	for _, m := range ms {
+		switch m.Kind {
+		case "stdout":
+			out += m.Body
+		case "stderr":
+			errOut += m.Body
+		}
+	}
+	if want := "it's 1\n" + dir + "\nhello\n"; out != want {
+		t.Errorf("stdout = %q, want %q", out, want)
+	}
+	if errOut != "oops\n" {
+		t.Errorf("stderr = %q, want the program's alone", errOut)
+	}
+	if m := ms[len(ms)-1]; m.ExitCode != 3 {
+		t.Errorf("got %+v, want exit status 3", m)
+	}
+
+	o = make(chan *Message)
+	c = collect(o)
+	p = StartProcessSpec(&ProcessSpec{Args: []string{"sleep", "60"}, Runner: s}, o)
+	select {
+	case <-p.run.(*sshRun).ready:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the remote pid was not reported")
+	}
+	p.Kill()
+	ms = <-c
+	if m := ms[len(ms)-1]; m.Reason != "killed" {
+		t.Errorf("got %+v, want the program killed", m)
+	}
+}
+
+func TestSSHExit255(t *testing.T) {
+	unixTools(t)
+	dir := t.TempDir()
+	// Like sshd, this fake reports a program terminated by a signal as
+	// status 255.
+	name := filepath.Join(dir, "ssh")
+	script := `#!/bin/sh
+while [ "$1" != -- ]; do shift; done
+case "$2" in
+down) echo "ssh: connect to host down port 22: Connection refused" >&2; exit 255;;
+esac
+sh -c "$3"
+s=$?
+[ $s -gt 128 ] && exit 255
+exit $s
+`
+	if err := ioutil.WriteFile(name, []byte(script), 0700); err != nil {
+		t.Fatal(err)
+	}
+	run := func(host string, args []string, kill bool) (*Message, []error) {
+		o := make(chan *Message)
+		c := collect(o)
+		p := StartProcessSpec(&ProcessSpec{Args: args, Runner: SSH{Host: host, Command: name}}, o)
+		if kill {
+			select {
+			case <-p.run.(*sshRun).ready:
+			case <-time.After(5 * time.Second):
+				t.Fatal("the remote pid was not reported")
+			}
+			p.Kill()
+		}
+		ms := <-c
+		<-p.Done
+		var errs []error
+		for err := range p.Err() {
+			errs = append(errs, err)
+		}
+		return ms[len(ms)-1], errs
+	}
+	if m, errs := run("up", []string{"sh", "-c", "exit 255"}, false); m.ExitCode != 255 || len(errs) != 0 {
+		t.Errorf("program exiting 255: got %+v, errors %v", m, errs)
+	}
+	if m, errs := run("up", []string{"sleep", "60"}, true); m.Signal != "SIGKILL" || m.Reason != "killed" || len(errs) != 0 {
+		t.Errorf("killed program: got %+v, errors %v", m, errs)
+	}
+	if _, errs := run("down", []string{"true"}, false); len(errs) != 1 {
+		t.Errorf("failed connection: errors %v, want ssh's failure", errs)
+	}
+}
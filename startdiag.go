@@ -0,0 +1,172 @@
+// Copyright 2012 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package process
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+// A StartError explains why a program could not be started, in terms a
+// user can act on. Kind is one of:
+//
+//	"not-found"         there is no such program
+//	"bad-dir"           the working directory does not exist
+//	"is-directory"      the program is a directory
+//	"not-executable"    the program lacks execute permission
+//	"noexec-mount"      it is on a file system mounted noexec
+//	"permission-denied" it cannot be run for some other lack of permission
+//	"bad-interpreter"   its #! interpreter or dynamic loader is missing
+//	"bad-format"        it is not a program for this system
+type StartError struct {
+	Program string // the program, as it was to be run
+	Kind    string
+	Detail  string // what is wrong, such as "no such file"
+	Err     error  // the error from starting it
+}
+
+func (e *StartError) Error() string {
+	return "cannot run " + e.Program + ": " + e.Detail
+}
+
+func (e *StartError) Unwrap() error {
+	return e.Err
+}
+
+// diagnose returns a *StartError explaining err, the error from starting
+// cmd, or err itself if it cannot tell what went wrong.
+func diagnose(cmd *exec.Cmd, err error) error {
+	e := &StartError{Program: cmd.Path, Err: err}
+	path := cmd.Path
+	if !filepath.IsAbs(path) && cmd.Dir != "" {
+		path = filepath.Join(cmd.Dir, path)
+	}
+	if errors.Is(err, exec.ErrNotFound) {
+		e.Kind, e.Detail = "not-found", "no such program in $PATH"
+		return e
+	}
+	if cmd.Dir != "" {
+		if _, serr := os.Stat(cmd.Dir); serr != nil {
+			e.Kind, e.Detail = "bad-dir", "working directory "+cmd.Dir+" does not exist"
+			return e
+		}
+	}
+	fi, serr := os.Stat(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		if serr != nil {
+			e.Kind, e.Detail = "not-found", "no such file"
+			return e
+		}
+		e.Kind = "bad-interpreter"
+		if interp := interpreter(path); interp != "" {
+			e.Detail = "its #! interpreter " + interp + " does not exist"
+		} else {
+			e.Detail = "its dynamic loader does not exist; it may be built for another system"
+		}
+	case errors.Is(err, os.ErrPermission):
+		switch {
+		case serr != nil:
+			e.Kind, e.Detail = "permission-denied", serr.Error()
+		case fi.IsDir():
+			e.Kind, e.Detail = "is-directory", "it is a directory"
+		case fi.Mode()&0111 == 0:
+			e.Kind, e.Detail = "not-executable", "it is not executable; chmod +x it"
+		case noexecMount(path):
+			e.Kind, e.Detail = "noexec-mount", "it is on a file system mounted noexec"
+		default:
+			e.Kind, e.Detail = "permission-denied", "permission denied; check the directories leading to it and its #! interpreter"
+		}
+	case isExecFormat(err):
+		e.Kind, e.Detail = "bad-format", "it is not a program for this system; a script needs a #! line"
+		if interpreter(path) != "" {
+			e.Detail = "its #! line is malformed or names a script"
+		}
+	default:
+		return err
+	}
+	return e
+}
+
+// diagnoseIn is diagnose for cmd's program run with root, if it is not
+// "", as its root directory, its Path and Dir being inside root.
+func diagnoseIn(root string, cmd *exec.Cmd, err error) error {
+	c := &exec.Cmd{Path: cmd.Path, Dir: cmd.Dir}
+	if root != "" {
+		if filepath.IsAbs(c.Path) {
+			c.Path = filepath.Join(root, c.Path)
+		}
+		c.Dir = filepath.Join(root, c.Dir)
+	}
+	err = diagnose(c, err)
+	if e, ok := err.(*StartError); ok {
+		e.Program = cmd.Path
+	}
+	return err
+}
+
+// checkProgram returns a *StartError if cmd's program, run under root as
+// for diagnoseIn, evidently cannot be executed, or nil if it may be.
+func checkProgram(root string, cmd *exec.Cmd) error {
+	if cmd.Err != nil {
+		return diagnoseIn(root, cmd, cmd.Err)
+	}
+	dir := cmd.Dir
+	if root != "" {
+		dir = filepath.Join(root, dir)
+	}
+	path := cmd.Path
+	if !filepath.IsAbs(path) && dir != "" {
+		path = filepath.Join(dir, path)
+	} else if root != "" {
+		path = filepath.Join(root, path)
+	}
+	fi, err := os.Stat(path)
+	switch {
+	case dir != "" && !exists(dir):
+		err = os.ErrNotExist
+	case err != nil:
+	case fi.IsDir() || fi.Mode()&0111 == 0:
+		err = os.ErrPermission
+	case interpreter(path) != "" && !exists(filepath.Join(root, interpreter(path))):
+		err = os.ErrNotExist
+	default:
+		return nil
+	}
+	return diagnoseIn(root, cmd, &os.PathError{Op: "exec", Path: cmd.Path, Err: err})
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// interpreter returns the interpreter named on the #! line of the file
+// at path, or "" if it has none.
+func interpreter(path string) string {
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	b := make([]byte, 256)
+	n, _ := io.ReadFull(f, b)
+	b = b[:n]
+	if !bytes.HasPrefix(b, []byte("#!")) {
+		return ""
+	}
+	if i := bytes.IndexByte(b, '\n'); i >= 0 {
+		b = b[:i]
+	}
+	f2 := bytes.Fields(b[2:])
+	if len(f2) == 0 {
+		return ""
+	}
+	return string(f2[0])
+}
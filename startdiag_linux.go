@@ -0,0 +1,15 @@
+// Copyright 2012 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package process
+
+import "syscall"
+
+const stNoExec = 8 // ST_NOEXEC, in Statfs_t.Flags
+
+// noexecMount reports whether path is on a file system mounted noexec.
+func noexecMount(path string) bool {
+	var st syscall.Statfs_t
+	return syscall.Statfs(path, &st) == nil && st.Flags&stNoExec != 0
+}
@@ -0,0 +1,12 @@
+// Copyright 2012 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !linux
+
+package process
+
+// noexecMount reports false, as mount flags are not checked here.
+func noexecMount(path string) bool {
+	return false
+}
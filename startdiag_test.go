@@ -0,0 +1,99 @@
+package process
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDiagnose(t *testing.T) {
+	unixTools(t)
+	dir := t.TempDir()
+	write := func(name, contents string, mode os.FileMode) {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(contents), mode); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("noperm", "#!/bin/sh\n", 0600)
+	write("nointerp", "#!/no/such/sh\n", 0700)
+	write("noshebang", "echo hi\n", 0700)
+	for _, tt := range []struct {
+		args []string
+		dir  string
+		kind string
+	}{
+		{[]string{"no-such-program-anywhere"}, "", "not-found"},
+		{[]string{"./missing"}, dir, "not-found"},
+		{[]string{"./noperm"}, dir, "not-executable"},
+		{[]string{"./nointerp"}, dir, "bad-interpreter"},
+		{[]string{"./noshebang"}, dir, "bad-format"},
+		{[]string{dir}, "", "is-directory"},
+		{[]string{"true"}, filepath.Join(dir, "gone"), "bad-dir"},
+	} {
+		cmd := exec.Command(tt.args[0], tt.args[1:]...)
+		cmd.Dir = tt.dir
+		err := cmd.Start()
+		if err == nil {
+			cmd.Wait()
+			t.Errorf("%v started", tt.args)
+			continue
+		}
+		var e *StartError
+		if !errors.As(diagnose(cmd, err), &e) || e.Kind != tt.kind {
+			t.Errorf("%v: got %v, want a %s StartError", tt.args, diagnose(cmd, err), tt.kind)
+		}
+	}
+
+	o := make(chan *Message)
+	c := collect(o)
+	StartProcessSpec(&ProcessSpec{Dir: dir, Args: []string{"./noperm"}}, o)
+	if ms := <-c; !strings.Contains(ms[0].Body, "chmod +x") {
+		t.Errorf("got %+v, want advice to make the program executable", ms[0])
+	}
+}
+
+func TestDiagnoseHelper(t *testing.T) {
+	unixTools(t)
+	dir := t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(dir, "noshebang"), []byte("echo hi\n"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	specs := map[string]func(*ProcessSpec){
+		"Limits": func(spec *ProcessSpec) { spec.Limits = Limits{OpenFiles: 64} },
+	}
+	if profile, err := DefaultSeccompProfile(); err == nil {
+		specs["Seccomp"] = func(spec *ProcessSpec) { spec.Seccomp = profile }
+	}
+	end := func(spec *ProcessSpec) *Message {
+		o := make(chan *Message)
+		c := collect(o)
+		if p := StartProcessSpec(spec, o); p != nil {
+			t.Errorf("%v started", spec.Args)
+		}
+		return (<-c)[0]
+	}
+	for _, tt := range []struct {
+		args []string
+		dir  string
+	}{
+		{[]string{"no-such-program-anywhere"}, ""},
+		{[]string{"./missing"}, dir},
+		{[]string{"./noshebang"}, dir},
+		{[]string{dir}, ""},
+		{[]string{"true"}, filepath.Join(dir, "gone")},
+	} {
+		// The helper must not change how the failure is reported.
+		want := end(&ProcessSpec{Args: tt.args, Dir: tt.dir})
+		for name, set := range specs {
+			spec := &ProcessSpec{Args: tt.args, Dir: tt.dir}
+			set(spec)
+			if m := end(spec); m.Kind != "end" || m.Reason != "start-failed" || m.Body != want.Body {
+				t.Errorf("%v with %s: got %+v, want %q", tt.args, name, m, want.Body)
+			}
+		}
+	}
+}
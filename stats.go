@@ -0,0 +1,58 @@
+// Copyright 2012 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package process
+
+import "time"
+
+// Stats counts the data a program has exchanged with its Process and the
+// resources it used. Output of hooks is not included.
+type Stats struct {
+	StdinBytes  int64
+	StdoutBytes int64
+	StderrBytes int64
+
+	// Elapsed is how long the program has been running, or how long it
+	// ran once it has exited.
+	Elapsed time.Duration
+
+	// CPU is the processor time the program used, and MaxRSS the most
+	// memory it had resident, in bytes. They are only known once it has
+	// exited, and only where the system reports them.
+	CPU    time.Duration
+	MaxRSS int64
+}
+
+// Throughput returns the bytes exchanged on all three streams per second
+// of Elapsed time.
+func (s *Stats) Throughput() float64 {
+	if s.Elapsed <= 0 {
+		return 0
+	}
+	return float64(s.StdinBytes+s.StdoutBytes+s.StderrBytes) / s.Elapsed.Seconds()
+}
+
+// Stats returns the Process' data counts so far. They are zero until the
+// program has started.
+func (p *Process) Stats() Stats {
+	p.mu.Lock()
+	begin, end, status := p.begin, p.exit, p.status
+	p.mu.Unlock()
+	s := Stats{
+		StdinBytes:  p.stdinN.Load(),
+		StdoutBytes: p.stdoutN.Load(),
+		StderrBytes: p.stderrN.Load(),
+	}
+	switch {
+	case begin.IsZero():
+	case end.IsZero():
+		s.Elapsed = time.Since(begin)
+	default:
+		s.Elapsed = end.Sub(begin)
+	}
+	if status != nil {
+		s.CPU, s.MaxRSS = status.CPU, status.MaxRSS
+	}
+	return s
+}
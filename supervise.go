@@ -0,0 +1,171 @@
+// Copyright 2012 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package process
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// maxBackoff is the longest a Supervisor waits before a restart.
+const maxBackoff = time.Minute
+
+// A Supervisor runs a long-lived program, such as a service, restarting
+// it when it fails as its ProcessSpec's RestartOnFailure, MaxRestarts
+// and Backoff say. The Messages of every run carry the Supervisor's id.
+// A run that is followed by a restart ends with a "restarting" Message in
+// place of its "end" Message, with the same fields and the wait before
+// the restart, such as "2s", as its Body; only the last run's "end"
+// Message is sent. A run that exits with status 0, could not be started,
+// or was stopped by Kill is not restarted.
+type Supervisor struct {
+	id   string
+	spec *ProcessSpec
+	out  chan<- *Message
+	Done chan struct{} // closed once the "end" Message has been sent
+
+	mu       sync.Mutex
+	killed   bool
+	kill     chan struct{} // closed when killed is set
+	proc     *Process      // the current run, if any
+	restarts int
+}
+
+// Supervise starts running spec under a Supervisor, sending the Messages
+// of each run on out.
+func Supervise(spec *ProcessSpec, out chan<- *Message) *Supervisor {
+	s := &Supervisor{
+		id:   newID(),
+		spec: spec,
+		out:  out,
+		Done: make(chan struct{}),
+		kill: make(chan struct{}),
+	}
+	go s.run()
+	return s
+}
+
+// Kill stops the current run, cancels any restart, and waits for the
+// Supervisor to finish.
+func (s *Supervisor) Kill() {
+	s.mu.Lock()
+	if !s.killed {
+		s.killed = true
+		close(s.kill)
+	}
+	p := s.proc
+	s.mu.Unlock()
+	p.Kill()
+	<-s.Done
+}
+
+// Restarts returns the number of times the program has been restarted.
+func (s *Supervisor) Restarts() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.restarts
+}
+
+// Handle handles m, a Message from the client, as Process.Handle does
+// for the current run, except that a "kill" Message kills the Supervisor.
+func (s *Supervisor) Handle(m *Message) error {
+	if m.Kind == "kill" {
+		s.Kill()
+		return nil
+	}
+	s.mu.Lock()
+	p := s.proc
+	s.mu.Unlock()
+	if p == nil {
+		return errors.New("process not running")
+	}
+	return p.Handle(m)
+}
+
+func (s *Supervisor) run() {
+	defer close(s.Done)
+	for {
+		m := s.runOnce()
+		wait, ok := s.next(m)
+		if !ok {
+			s.out <- m
+			return
+		}
+		m.Kind, m.Body = "restarting", wait.String()
+		s.out <- m
+		t := time.NewTimer(wait)
+		select {
+		case <-t.C:
+		case <-s.kill:
+			t.Stop()
+			m := newMessage(s.id, "end", "")
+			m.Reason = "killed"
+			s.out <- m
+			return
+		}
+	}
+}
+
+// runOnce runs the program once, relaying its output, and returns its
+// "end" Message.
+func (s *Supervisor) runOnce() *Message {
+	ch := make(chan *Message)
+	started := make(chan *Process, 1)
+	go func() {
+		p := StartProcessSpec(s.spec, ch)
+		if p != nil {
+			s.mu.Lock()
+			s.proc = p
+			kill := s.killed
+			s.mu.Unlock()
+			if kill {
+				go p.Kill()
+			}
+		}
+		started <- p
+	}()
+	var end *Message
+	for m := range ch {
+		m.Id = s.id
+		if m.Kind == "end" {
+			end = m
+			break
+		}
+		s.out <- m
+	}
+	if p := <-started; p != nil {
+		<-p.Done
+		s.mu.Lock()
+		s.proc = nil
+		s.mu.Unlock()
+	}
+	return end
+}
+
+// next reports whether the run that ended with m is to be followed by a
+// restart and, if so, how long to wait before it.
+func (s *Supervisor) next(m *Message) (time.Duration, bool) {
+	if !s.spec.RestartOnFailure || m.Stats == nil || m.ExitCode == 0 && m.Signal == "" {
+		return 0, false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.killed || s.spec.MaxRestarts > 0 && s.restarts >= s.spec.MaxRestarts {
+		return 0, false
+	}
+	wait := s.spec.Backoff
+	if wait <= 0 {
+		wait = time.Second
+	}
+	for i := 0; i < s.restarts && wait < maxBackoff; i++ {
+		wait *= 2
+	}
+	if wait > maxBackoff {
+		wait = maxBackoff
+	}
+	s.restarts++
+	return wait, true
+}
@@ -0,0 +1,102 @@
+package process
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSupervisorRestarts(t *testing.T) {
+	unixTools(t)
+	out := make(chan *Message)
+	ms := collect(out)
+	s := Supervise(&ProcessSpec{
+		Args:             []string{"sh", "-c", "echo run; exit 3"},
+		RestartOnFailure: true,
+		MaxRestarts:      2,
+		Backoff:          10 * time.Millisecond,
+	}, out)
+	var kinds []string
+	for _, m := range <-ms {
+		if m.Id != s.id {
+			t.Errorf("Message %s has Id %q, want %q", m.Kind, m.Id, s.id)
+		}
+		kinds = append(kinds, m.Kind+":"+m.Body)
+		if m.Kind == "restarting" || m.Kind == "end" {
+			if m.ExitCode != 3 || m.Reason != "exited" {
+				t.Errorf("%s Message has ExitCode %d, Reason %q", m.Kind, m.ExitCode, m.Reason)
+			}
+		}
+	}
+	<-s.Done
+	want := "stdout:run\n,restarting:10ms,stdout:run\n,restarting:20ms,stdout:run\n,end:exit status 3"
+	if got := strings.Join(kinds, ","); got != want {
+		t.Errorf("got Messages\n\t%s\nwant\n\t%s", got, want)
+	}
+	if n := s.Restarts(); n != 2 {
+		t.Errorf("Restarts() = %d, want 2", n)
+	}
+}
+
+func TestSupervisorSuccess(t *testing.T) {
+	unixTools(t)
+	out := make(chan *Message)
+	ms := collect(out)
+	s := Supervise(&ProcessSpec{Args: []string{"true"}, RestartOnFailure: true}, out)
+	got := <-ms
+	if len(got) != 1 || got[0].Kind != "end" || got[0].Body != "" {
+		t.Errorf("got %d Messages, last %+v", len(got), got[len(got)-1])
+	}
+	if n := s.Restarts(); n != 0 {
+		t.Errorf("Restarts() = %d, want 0", n)
+	}
+}
+
+func TestSupervisorKill(t *testing.T) {
+	unixTools(t)
+	out := make(chan *Message)
+	ms := collect(out)
+	s := Supervise(&ProcessSpec{
+		Args:             []string{"false"},
+		RestartOnFailure: true,
+		Backoff:          time.Hour,
+	}, out)
+	time.AfterFunc(100*time.Millisecond, s.Kill)
+	got := <-ms
+	if len(got) != 2 || got[0].Kind != "restarting" || got[1].Kind != "end" || got[1].Reason != "killed" {
+		for _, m := range got {
+			t.Logf("%+v", m)
+		}
+		t.Errorf("want a restart and then a killed end")
+	}
+	<-s.Done
+
+	// Killing a running program stops it for good.
+	out = make(chan *Message)
+	ms = collect(out)
+	s = Supervise(&ProcessSpec{Args: []string{"sleep", "10"}, RestartOnFailure: true}, out)
+	time.AfterFunc(100*time.Millisecond, s.Kill)
+	got = <-ms
+	if m := got[len(got)-1]; m.Kind != "end" || m.Reason != "killed" {
+		t.Errorf("last Message %+v, want a killed end", m)
+	}
+}
+
+func TestSupervisorBackoff(t *testing.T) {
+	failed := &Message{Kind: "end", ExitCode: 1, Stats: &Stats{}}
+	for _, tt := range []struct {
+		backoff time.Duration
+		want    []time.Duration
+	}{
+		{0, []time.Duration{time.Second, 2 * time.Second, 4 * time.Second}},
+		{40 * time.Second, []time.Duration{40 * time.Second, time.Minute, time.Minute}},
+		{time.Hour, []time.Duration{time.Minute, time.Minute}},
+	} {
+		s := &Supervisor{spec: &ProcessSpec{RestartOnFailure: true, Backoff: tt.backoff}}
+		for i, want := range tt.want {
+			if wait, ok := s.next(failed); !ok || wait != want {
+				t.Errorf("Backoff %v, restart %d: waited %v, want %v", tt.backoff, i+1, wait, want)
+			}
+		}
+	}
+}
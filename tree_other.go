@@ -0,0 +1,33 @@
+// Copyright 2012 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !unix && !windows
+
+package process
+
+import (
+	"os"
+	"os/exec"
+)
+
+func newGroup(cmd *exec.Cmd) {}
+
+// track does nothing; see Kill.
+func (p *Process) track(cmd *exec.Cmd) error {
+	return nil
+}
+
+func (r *execRun) Signal(sig os.Signal) error {
+	return r.cmd.Process.Signal(sig)
+}
+
+// killLeaked returns 0; see Kill.
+func (r *execRun) killLeaked() int {
+	return 0
+}
+
+// Kill kills the program. Processes it started are left running.
+func (r *execRun) Kill() error {
+	return r.cmd.Process.Kill()
+}
@@ -0,0 +1,57 @@
+// Copyright 2012 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build unix
+
+package process
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// newGroup arranges for cmd to run in a new process group, which the
+// processes it starts join too, so that Kill ends them all. In PTY mode
+// the program leads a new session and so a new group already.
+func newGroup(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = new(syscall.SysProcAttr)
+	}
+	if !cmd.SysProcAttr.Setsid {
+		cmd.SysProcAttr.Setpgid = true
+	}
+}
+
+// track does nothing; see newGroup.
+func (p *Process) track(cmd *exec.Cmd) error {
+	return nil
+}
+
+// Signal sends sig to the program's process group.
+func (r *execRun) Signal(sig os.Signal) error {
+	s, ok := sig.(syscall.Signal)
+	if !ok {
+		return r.cmd.Process.Signal(sig)
+	}
+	return syscall.Kill(-r.cmd.Process.Pid, s)
+}
+
+// Kill kills the program's process group.
+func (r *execRun) Kill() error {
+	return r.Signal(syscall.SIGKILL)
+}
+
+// killLeaked kills the processes left in the program's process group
+// after it has exited, and returns how many there were. They are stopped
+// first so that none can escape by starting another while being counted.
+func (r *execRun) killLeaked() int {
+	pid := r.cmd.Process.Pid
+	if syscall.Kill(-pid, syscall.SIGSTOP) != nil {
+		return 0 // the group is empty
+	}
+	n := groupSize(pid)
+	syscall.Kill(-pid, syscall.SIGKILL)
+	return n
+}
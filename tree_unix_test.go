@@ -0,0 +1,48 @@
+//go:build unix
+
+package process
+
+import (
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestKillTree(t *testing.T) {
+	o := make(chan *Message)
+	go drain(o)
+	// sh waits for sleep, so the Process cannot end while it runs.
+	p := StartProcess(nil, []string{"sh", "-c", "sleep 60 & wait"}, o)
+	time.Sleep(100 * time.Millisecond)
+	killed := make(chan bool)
+	go func() {
+		p.Kill()
+		close(killed)
+	}()
+	select {
+	case <-killed:
+	case <-time.After(10 * time.Second):
+		t.Fatal("Kill left the grandchild running")
+	}
+}
+
+func TestLeaked(t *testing.T) {
+	o := make(chan *Message)
+	c := collect(o)
+	start := time.Now()
+	StartProcess(nil, []string{"sh", "-c", "sleep 60 & sleep 60 & echo hi"}, o)
+	ms := <-c
+	if d := time.Since(start); d > 10*time.Second {
+		t.Errorf("the Process took %v to end, want the sleeps killed", d)
+	}
+	if ms[0].Body != "hi\n" {
+		t.Errorf("got %+v, want the program's output", ms[0])
+	}
+	want := 2
+	if runtime.GOOS != "linux" {
+		want = 1 // only Linux counts them
+	}
+	if m := ms[len(ms)-1]; m.Leaked != want || m.Reason != "exited" {
+		t.Errorf("got %+v, want %d leaked processes", m, want)
+	}
+}
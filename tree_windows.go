@@ -0,0 +1,169 @@
+// Copyright 2012 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package process
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"syscall"
+	"unsafe"
+)
+
+var (
+	kernel32                      = syscall.NewLazyDLL("kernel32.dll")
+	procCreateJobObjectW          = kernel32.NewProc("CreateJobObjectW")
+	procAssignProcessToJobObject  = kernel32.NewProc("AssignProcessToJobObject")
+	procTerminateJobObject        = kernel32.NewProc("TerminateJobObject")
+	procQueryInformationJobObject = kernel32.NewProc("QueryInformationJobObject")
+	procThread32First             = kernel32.NewProc("Thread32First")
+	procThread32Next              = kernel32.NewProc("Thread32Next")
+	procOpenThread                = kernel32.NewProc("OpenThread")
+	procResumeThread              = kernel32.NewProc("ResumeThread")
+)
+
+const (
+	processSetQuota                     = 0x0100 // PROCESS_SET_QUOTA
+	threadSuspendResume                 = 0x0002 // THREAD_SUSPEND_RESUME
+	createSuspended                     = 0x0004 // CREATE_SUSPENDED
+	jobObjectBasicAccountingInformation = 1
+)
+
+// threadEntry is THREADENTRY32.
+type threadEntry struct {
+	Size         uint32
+	Usage        uint32
+	ThreadID     uint32
+	OwnerProcess uint32
+	BasePri      int32
+	DeltaPri     int32
+	Flags        uint32
+}
+
+// jobAccounting is JOBOBJECT_BASIC_ACCOUNTING_INFORMATION.
+type jobAccounting struct {
+	TotalUserTime             int64
+	TotalKernelTime           int64
+	ThisPeriodTotalUserTime   int64
+	ThisPeriodTotalKernelTime int64
+	TotalPageFaultCount       uint32
+	TotalProcesses            uint32
+	ActiveProcesses           uint32
+	TotalTerminatedProcesses  uint32
+}
+
+// newGroup has the program created suspended, so that track can put it
+// in a Job Object before it runs.
+func newGroup(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = new(syscall.SysProcAttr)
+	}
+	cmd.SysProcAttr.CreationFlags |= createSuspended
+}
+
+// track puts the started program in a new Job Object, which processes it
+// starts join too, so that Kill ends them all, and then lets it run. If
+// it cannot be let run, it is killed.
+func (p *Process) track(cmd *exec.Cmd) error {
+	err := p.join(cmd)
+	if rerr := resume(uint32(cmd.Process.Pid)); rerr != nil {
+		cmd.Process.Kill()
+		return rerr
+	}
+	return err
+}
+
+// join puts the program in a new Job Object.
+func (p *Process) join(cmd *exec.Cmd) error {
+	job, _, err := procCreateJobObjectW.Call(0, 0)
+	if job == 0 {
+		return errors.New("creating job object: " + err.Error())
+	}
+	h, err := syscall.OpenProcess(processSetQuota|syscall.PROCESS_TERMINATE, false, uint32(cmd.Process.Pid))
+	if err != nil {
+		syscall.CloseHandle(syscall.Handle(job))
+		return errors.New("opening process: " + err.Error())
+	}
+	defer syscall.CloseHandle(h)
+	if ok, _, err := procAssignProcessToJobObject.Call(job, uintptr(h)); ok == 0 {
+		syscall.CloseHandle(syscall.Handle(job))
+		return errors.New("assigning process to job object: " + err.Error())
+	}
+	p.job = job
+	p.onRelease(func() {
+		p.mu.Lock()
+		syscall.CloseHandle(syscall.Handle(p.job))
+		p.job = 0
+		p.mu.Unlock()
+	})
+	return nil
+}
+
+// resume resumes the main thread of the suspended process pid, its only
+// thread.
+func resume(pid uint32) error {
+	snap, err := syscall.CreateToolhelp32Snapshot(syscall.TH32CS_SNAPTHREAD, 0)
+	if err != nil {
+		return errors.New("listing threads: " + err.Error())
+	}
+	defer syscall.CloseHandle(snap)
+	te := threadEntry{Size: uint32(unsafe.Sizeof(threadEntry{}))}
+	ok, _, err := procThread32First.Call(uintptr(snap), uintptr(unsafe.Pointer(&te)))
+	for ; ok != 0; ok, _, err = procThread32Next.Call(uintptr(snap), uintptr(unsafe.Pointer(&te))) {
+		if te.OwnerProcess != pid {
+			continue
+		}
+		h, _, err := procOpenThread.Call(threadSuspendResume, 0, uintptr(te.ThreadID))
+		if h == 0 {
+			return errors.New("opening thread: " + err.Error())
+		}
+		defer syscall.CloseHandle(syscall.Handle(h))
+		if n, _, err := procResumeThread.Call(h); int32(n) == -1 {
+			return errors.New("resuming thread: " + err.Error())
+		}
+		return nil
+	}
+	return errors.New("listing threads: " + err.Error())
+}
+
+// Signal sends sig to the program alone, as only killing is supported.
+func (r *execRun) Signal(sig os.Signal) error {
+	return r.cmd.Process.Signal(sig)
+}
+
+// Kill terminates every process in the program's Job Object, or just the
+// program if it is not in one.
+func (r *execRun) Kill() error {
+	p := r.p
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.job != 0 {
+		ok, _, err := procTerminateJobObject.Call(p.job, 1)
+		if ok != 0 {
+			return nil
+		}
+		p.fail(errors.New("terminating job object: " + err.Error()))
+	}
+	return r.cmd.Process.Kill()
+}
+
+// killLeaked terminates the processes left in the program's Job Object
+// after it has exited, and returns how many there were.
+func (r *execRun) killLeaked() int {
+	p := r.p
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.job == 0 {
+		return 0
+	}
+	var info jobAccounting
+	ok, _, _ := procQueryInformationJobObject.Call(p.job, jobObjectBasicAccountingInformation,
+		uintptr(unsafe.Pointer(&info)), unsafe.Sizeof(info), 0)
+	if ok == 0 || info.ActiveProcesses == 0 {
+		return 0
+	}
+	procTerminateJobObject.Call(p.job, 1)
+	return int(info.ActiveProcesses)
+}
@@ -0,0 +1,24 @@
+package process
+
+import (
+	"testing"
+	"time"
+)
+
+func TestKillTree(t *testing.T) {
+	o := make(chan *Message)
+	go drain(o)
+	// ping inherits cmd's stdout, so the Process cannot end while it runs.
+	p := StartProcess(nil, []string{"cmd", "/c", "ping -n 60 127.0.0.1"}, o)
+	time.Sleep(500 * time.Millisecond)
+	killed := make(chan bool)
+	go func() {
+		p.Kill()
+		close(killed)
+	}()
+	select {
+	case <-killed:
+	case <-time.After(10 * time.Second):
+		t.Fatal("Kill left the grandchild running")
+	}
+}
@@ -0,0 +1,37 @@
+// Copyright 2012 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package process
+
+import "errors"
+
+// A User gives the identity a program runs as, so that a server running
+// as root can run untrusted programs as an unprivileged user such as
+// nobody. Switching users is only supported on Unix, and needs the
+// server to have the privilege to do it.
+type User struct {
+	Uid    uint32
+	Gid    uint32
+	Groups []uint32 // supplementary group ids; nil means none
+
+	// AllowRoot must be set for Uid, Gid or one of Groups to be 0, so
+	// that a zero User cannot run a program as root by mistake.
+	AllowRoot bool
+}
+
+// check reports an error if u would run a program as root without
+// AllowRoot. A nil User is valid.
+func (u *User) check() error {
+	if u == nil || u.AllowRoot {
+		return nil
+	}
+	root := u.Uid == 0 || u.Gid == 0
+	for _, g := range u.Groups {
+		root = root || g == 0
+	}
+	if root {
+		return errors.New("refusing to run as root without User.AllowRoot")
+	}
+	return nil
+}
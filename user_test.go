@@ -0,0 +1,34 @@
+package process
+
+import (
+	"os"
+	"testing"
+)
+
+func TestUser(t *testing.T) {
+	unixTools(t)
+	o := make(chan *Message)
+	c := collect(o)
+	if p := StartProcessSpec(&ProcessSpec{Args: []string{"id"}, User: &User{Gid: 65534}}, o); p != nil {
+		t.Errorf("started as uid 0 without AllowRoot")
+		p.Kill()
+	}
+	<-c
+
+	if os.Geteuid() != 0 {
+		t.Skip("switching users needs root")
+	}
+	o = make(chan *Message)
+	c = collect(o)
+	StartProcessSpec(&ProcessSpec{
+		Args: []string{"sh", "-c", "id -u; id -g; id -G"},
+		User: &User{Uid: 65534, Gid: 65534, Groups: []uint32{65533}},
+	}, o)
+	var out string
+	for _, m := range <-c {
+		out += m.Body
+	}
+	if out != "65534\n65534\n65534 65533\n" {
+		t.Errorf("output = %q, want the program run as 65534", out)
+	}
+}